@@ -12,7 +12,7 @@ type argT struct {
 }
 
 func main() {
-	cli.GetEditor = func() (string, error) {
+	cli.DefaultApp().Editor = func() (string, error) {
 		if editor := os.Getenv("EDITOR"); editor != "" {
 			return editor, nil
 		}
@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/color"
+	"github.com/mattn/go-colorable"
+)
+
+// ColorPolicy decides whether output should be colorized
+type ColorPolicy int
+
+const (
+	// ColorAuto colorizes output only if it's a terminal
+	ColorAuto ColorPolicy = iota
+	// ColorAlways always colorizes output
+	ColorAlways
+	// ColorNever never colorizes output
+	ColorNever
+)
+
+// App represents a command-line application. It owns the root command and
+// all settings used to run it, so that several command trees with different
+// settings can live in one process.
+type App struct {
+	// Root is the root command of app
+	Root *Command
+
+	// Name and Version of app
+	Name    string
+	Version string
+
+	// Color decides whether output should be colorized
+	Color ColorPolicy
+
+	// Stdin, Stdout and Stderr of app, standard streams used if nil
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Editor resolves editor command, which may contain arguments like
+	// `code --wait`. $VISUAL, $EDITOR and DefaultEditor are tried in
+	// order if nil
+	Editor func() (string, error)
+
+	// LaunchEditor opens filename with editor and waits for it to exit,
+	// the editor runs with streams of the invocation if nil
+	LaunchEditor func(editor, filename string) error
+
+	// NoInput disables all interactive input like `--no-input` or
+	// NoInputEnv does, prompt and edit fields are left unset
+	NoInput bool
+
+	// AllowPipedInput allows prompts to read plain lines from a stdin which
+	// isn't a terminal, such as a pipe or a regular file
+	AllowPipedInput bool
+
+	// Debug prints stack of PanicError, which is enabled by DebugEnv, too
+	Debug bool
+
+	// NoSignalHandler stops Run of app and package-level Run from
+	// cancelling Context().Done() by the first SIGINT or SIGTERM, see
+	// Context.Context. Execute and Run of commands never handle signals
+	NoSignalHandler bool
+
+	// PromptTimeout limits time to answer a prompt, which could be
+	// overridden by `prompt-timeout` tag of field. No timeout if zero
+	PromptTimeout time.Duration
+
+	// Getenv looks up environment variables referenced by default values,
+	// os.Getenv used if nil
+	Getenv func(key string) string
+
+	// OnContext is called with the context of every invocation after
+	// flags parsed and validated, and before the command runs
+	OnContext func(*Context)
+
+	// ExitCode maps the error returned by command to exit status,
+	// ExitCodeOf used if nil
+	ExitCode func(error) int
+
+	// NoSynopsis hides the usage line generated by Synopsis of commands,
+	// e.g. for descriptions which have their own
+	NoSynopsis bool
+
+	// UsageTemplate is the text/template of usage executed with UsageData,
+	// which is overridden by UsageTemplate of commands. The builtin
+	// template of usage style is used if empty
+	UsageTemplate string
+
+	usageStyle int32
+
+	registryMu sync.RWMutex // protect parsers and completers
+	parsers    map[string]FlagParserCreator
+	completers map[string]Completer
+}
+
+// NewApp creates an App with root command
+func NewApp(root *Command) *App {
+	app := &App{
+		Root:    root,
+		parsers: make(map[string]FlagParserCreator),
+	}
+	if root != nil {
+		app.Name = root.Name
+	}
+	return app
+}
+
+var defaultApp = newDefaultApp()
+
+func newDefaultApp() *App {
+	app := NewApp(nil)
+	// the deprecated GetEditor is only used by default app
+	app.Editor = func() (string, error) {
+		if GetEditor != nil {
+			return GetEditor()
+		}
+		return app.envEditor()
+	}
+	return app
+}
+
+// DefaultApp returns the app used by package-level functions and Command.Run
+func DefaultApp() *App {
+	return defaultApp
+}
+
+func appOrDefault(app *App) *App {
+	if app == nil {
+		return defaultApp
+	}
+	return app
+}
+
+// UsageStyle gets style of usage
+func (app *App) UsageStyle() UsageStyle {
+	return UsageStyle(atomic.LoadInt32(&app.usageStyle))
+}
+
+// SetUsageStyle sets style of usage
+func (app *App) SetUsageStyle(style UsageStyle) {
+	atomic.StoreInt32(&app.usageStyle, int32(style))
+}
+
+// RegisterFlagParser registers FlagParserCreator by name for app
+func (app *App) RegisterFlagParser(name string, creator FlagParserCreator) {
+	app.registryMu.Lock()
+	defer app.registryMu.Unlock()
+	if app.parsers == nil {
+		app.parsers = make(map[string]FlagParserCreator)
+	}
+	if _, ok := app.parsers[name]; ok {
+		panic("RegisterFlagParser has registered: " + name)
+	}
+	app.parsers[name] = creator
+}
+
+// lookupFlagParser finds parser in app and then in default app
+func (app *App) lookupFlagParser(name string) FlagParserCreator {
+	app.registryMu.RLock()
+	creator, ok := app.parsers[name]
+	app.registryMu.RUnlock()
+	if !ok && app != defaultApp {
+		return defaultApp.lookupFlagParser(name)
+	}
+	return creator
+}
+
+// RegisterCompleter registers Completer by name for app, which is used by
+// fields with tag `complete:"func:name"`
+func (app *App) RegisterCompleter(name string, completer Completer) {
+	app.registryMu.Lock()
+	defer app.registryMu.Unlock()
+	if app.completers == nil {
+		app.completers = make(map[string]Completer)
+	}
+	if _, ok := app.completers[name]; ok {
+		panic("RegisterCompleter has registered: " + name)
+	}
+	app.completers[name] = completer
+}
+
+// lookupCompleter finds completer in app and then in default app
+func (app *App) lookupCompleter(name string) Completer {
+	app.registryMu.RLock()
+	completer, ok := app.completers[name]
+	app.registryMu.RUnlock()
+	if !ok && app != defaultApp {
+		return defaultApp.lookupCompleter(name)
+	}
+	return completer
+}
+
+func (app *App) editor() (string, error) {
+	if app.Editor != nil {
+		return app.Editor()
+	}
+	return app.envEditor()
+}
+
+// envEditor returns editor by $VISUAL and $EDITOR, or DefaultEditor
+func (app *App) envEditor() (string, error) {
+	for _, key := range []string{"VISUAL", "EDITOR"} {
+		if editor := app.getenv(key); editor != "" {
+			return editor, nil
+		}
+	}
+	return exec.LookPath(DefaultEditor)
+}
+
+func (app *App) getenv(key string) string {
+	if app.Getenv != nil {
+		return app.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func (app *App) stdin() io.Reader {
+	if app.Stdin != nil {
+		return app.Stdin
+	}
+	return os.Stdin
+}
+
+func (app *App) stdout() io.Writer {
+	if app.Stdout != nil {
+		return app.Stdout
+	}
+	return colorable.NewColorableStdout()
+}
+
+// console returns console with streams of app
+func (app *App) console() *console {
+	return app.newConsole(app.stdin(), app.stdout(), app.stderr(), false)
+}
+
+// newConsole creates console with interactive settings of app
+func (app *App) newConsole(stdin io.Reader, stdout, stderr io.Writer, noInput bool) *console {
+	c := newConsole(stdin, stdout, stderr)
+	c.noInput = noInput || app.NoInput || isTrue(app.getenv(NoInputEnv))
+	c.allowPipe = app.AllowPipedInput
+	c.timeout = app.PromptTimeout
+	return c
+}
+
+// output returns writer and it's file descriptors used to detect terminal
+func (app *App) output(w io.Writer) (io.Writer, []uintptr) {
+	if w != nil {
+		return w, nil
+	}
+	if app.Stdout != nil {
+		return app.Stdout, nil
+	}
+	return colorable.NewColorableStdout(), []uintptr{os.Stdout.Fd()}
+}
+
+func (app *App) stderr() io.Writer {
+	if app.Stderr != nil {
+		return app.Stderr
+	}
+	return colorable.NewColorableStderr()
+}
+
+// colorize enables or disables clr for writer w according to color policy
+func (app *App) colorize(clr *color.Color, w io.Writer, fds ...uintptr) {
+	switch app.Color {
+	case ColorAlways:
+		clr.Enable()
+	case ColorNever:
+		clr.Disable()
+	default:
+		colorSwitch(clr, w, fds...)
+	}
+}
+
+func (app *App) exitCode(err error) int {
+	if app.ExitCode != nil {
+		return app.ExitCode(err)
+	}
+	return ExitCodeOf(err)
+}
+
+// Run runs root command of app with args and returns exit status,
+// the error would be written to Stderr
+func (app *App) Run(args []string) int {
+	return app.runCommand(app.Root, args)
+}
+
+// Execute runs root command of app with args and returns the error
+func (app *App) Execute(args []string) error {
+	return app.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext is similar to Execute, but Context().Done() of the
+// invocation is closed if parent done
+func (app *App) ExecuteContext(parent context.Context, args []string) error {
+	return app.execute(parent, app.Root, args, false)
+}
+
+func (app *App) runCommand(cmd *Command, args []string) int {
+	err := app.execute(context.Background(), cmd, args, !app.NoSignalHandler)
+	if err != nil && err != ExitError && err.Error() != "" {
+		stderr := app.stderr()
+		fmt.Fprintln(stderr, err)
+		app.printStack(stderr, err)
+	}
+	return app.exitCode(err)
+}
+
+// DebugEnv is the environment variable which enables debug mode if it's true
+const DebugEnv = "CLI_DEBUG"
+
+func (app *App) debug() bool {
+	return app.Debug || isTrue(app.getenv(DebugEnv))
+}
+
+// printStack writes stack of PanicError to w in debug mode
+func (app *App) printStack(w io.Writer, err error) {
+	var pe *PanicError
+	if app.debug() && errors.As(err, &pe) {
+		w.Write(pe.Stack)
+	}
+}
+
+func (app *App) execute(parent context.Context, cmd *Command, args []string, signals bool) error {
+	if cmd == nil {
+		return fmt.Errorf("app has no root command")
+	}
+	if app.Version != "" && len(args) == 1 && args[0] == dashTwo+"version" && !cmd.hasFlag(args[0]) {
+		name := app.Name
+		if name == "" {
+			name = cmd.Name
+		}
+		fmt.Fprintln(app.stdout(), name, app.Version)
+		return nil
+	}
+	return cmd.run(&runOptions{app: app, ctx: parent, signals: signals}, args)
+}
+
+// Usage returns usage of cmd rendered with settings of app
+func (app *App) Usage(cmd *Command) string {
+	writer, fds := app.output(nil)
+	ctx := &Context{
+		app:     app,
+		command: cmd,
+		path:    cmd.Path(),
+		writer:  writer,
+		flagSet: newFlagSet(),
+	}
+	app.colorize(&ctx.color, writer, fds...)
+	ctx.width = app.width(writer, fds...)
+	return cmd.Usage(ctx)
+}
+
+// ServeHTTP implements HTTP handler with root command of app
+func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	app.Root.serveHTTP(app, w, r)
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type appParser struct {
+	ptr *string
+	tag string
+}
+
+func (p appParser) Parse(s string) error {
+	*p.ptr = p.tag + s
+	return nil
+}
+
+func TestAppSettings(t *testing.T) {
+	type argT struct {
+		Value string `cli:"v" usage:"value" dft:"x" parser:"app-parser"`
+	}
+	newRoot := func() *Command {
+		return &Command{
+			Name: "root",
+			Argv: func() interface{} { return new(argT) },
+			Fn: func(ctx *Context) error {
+				ctx.String(ctx.Argv().(*argT).Value)
+				return nil
+			},
+		}
+	}
+	for i, tt := range []struct {
+		style UsageStyle
+		tag   string
+		usage string
+	}{
+		{NormalStyle, "A:", "Usage: root [OPTIONS]\n\nOptions:\n\n  -v[=x]   value\n"},
+		{ManualStyle, "B:", "Usage: root [OPTIONS]\n\nOptions:\n\n  -v[=x]\n      value\n"},
+	} {
+		i, tt := i, tt
+		t.Run(tt.tag, func(t *testing.T) {
+			t.Parallel()
+			stdout := new(bytes.Buffer)
+			app := NewApp(newRoot())
+			app.Stdout = stdout
+			app.Color = ColorNever
+			app.SetUsageStyle(tt.style)
+			app.RegisterFlagParser("app-parser", func(ptr interface{}) FlagParser {
+				return appParser{ptr: ptr.(*string), tag: tt.tag}
+			})
+			for j := 0; j < 10; j++ {
+				stdout.Reset()
+				assert.Equal(t, 0, app.Run([]string{"-v", "1"}), "case %d", i)
+				assert.Equal(t, tt.tag+"1", stdout.String(), "case %d", i)
+			}
+			stdout.Reset()
+			app.Root.Fn = func(ctx *Context) error {
+				ctx.WriteUsage()
+				return nil
+			}
+			assert.Nil(t, app.Execute(nil))
+			assert.Equal(t, tt.usage, stdout.String(), "case %d", i)
+		})
+	}
+}
+
+func TestAppRun(t *testing.T) {
+	var (
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+	)
+	app := NewApp(&Command{
+		Name: "root",
+		Fn: func(ctx *Context) error {
+			if len(ctx.Args()) > 0 {
+				return errors.New(strings.Join(ctx.Args(), " "))
+			}
+			return nil
+		},
+		Argv:        func() interface{} { return new(struct{}) },
+		CanSubRoute: true,
+	})
+	app.Version = "v1.0.0"
+	app.Stdout = stdout
+	app.Stderr = stderr
+	app.ExitCode = func(err error) int {
+		if err != nil {
+			return 3
+		}
+		return 0
+	}
+
+	assert.Equal(t, 0, app.Run([]string{"--version"}))
+	assert.Equal(t, "root v1.0.0\n", stdout.String())
+	assert.Equal(t, 3, app.Run([]string{"oops"}))
+	assert.Equal(t, "oops\n", stderr.String())
+	assert.Error(t, NewApp(nil).Execute(nil))
+}
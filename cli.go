@@ -21,18 +21,13 @@ func RunWithArgs(argv interface{}, args []string, fn CommandFunc, descs ...strin
 	if len(descs) > 0 {
 		desc = strings.Join(descs, "\n")
 	}
-	err := (&Command{
+	return defaultApp.runCommand(&Command{
 		Name:        args[0],
 		Desc:        desc,
 		Argv:        func() interface{} { return argv },
 		CanSubRoute: true,
 		Fn:          fn,
-	}).Run(args[1:])
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return 1
-	}
-	return 0
+	}, args[1:])
 }
 
 // Root registers forest for root and returns root
@@ -57,11 +52,13 @@ func Parse(args []string, argv interface{}) error {
 }
 
 func parseArgv(args []string, argv interface{}, clr color.Color) *flagSet {
-	return parseArgvList(args, []interface{}{argv}, clr)
+	return parseArgvList(defaultApp, defaultApp.console(), args, []interface{}{argv}, clr)
 }
 
-func parseArgvList(args []string, argvList []interface{}, clr color.Color) *flagSet {
+func parseArgvList(app *App, cons *console, args []string, argvList []interface{}, clr color.Color) *flagSet {
 	flagSet := newFlagSet()
+	flagSet.app = app
+	flagSet.console = cons
 	for _, argv := range argvList {
 		if argv == nil {
 			continue
@@ -90,78 +87,66 @@ func parseArgvList(args []string, argvList []interface{}, clr color.Color) *flag
 }
 
 func usage(argvList []interface{}, clr color.Color, style UsageStyle) string {
-	flagSet := newFlagSet()
-	buf := bytes.NewBufferString("")
+	fs, ok := usageFlags(argvList)
+	if !ok {
+		return ""
+	}
+	return fs.StringWithStyle(clr, style)
+}
+
+// usageFlags returns flags of argvList in order of usage, which are not
+// bound to any value. It fails if any schema is invalid or names conflict.
+func usageFlags(argvList []interface{}) (flagSlice, bool) {
+	var (
+		fs    = flagSlice{}
+		names = make(map[string]bool)
+	)
 	for i := len(argvList) - 1; i >= 0; i-- {
 		v := argvList[i]
 		if v == nil {
 			continue
 		}
-		var (
-			typ = reflect.TypeOf(v)
-			val = reflect.ValueOf(v)
-		)
-		if typ.Kind() == reflect.Ptr &&
-			reflect.Indirect(val).Type().Kind() == reflect.Struct {
-			// initialize flagSet
-			initFlagSet(typ, val, flagSet, clr, true)
-			if flagSet.err != nil {
-				return ""
+		typ := reflect.TypeOf(v)
+		if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		schema := getArgvSchema(typ.Elem())
+		if schema.err != nil {
+			return nil, false
+		}
+		for _, field := range schema.fields {
+			for _, name := range field.tag.names() {
+				if names[name] {
+					return nil, false
+				}
+				names[name] = true
 			}
+			fs = append(fs, &flag{field: field.field, schema: field, tag: field.tag})
 		}
 	}
-	buf.WriteString(flagSlice(flagSet.flagSlice).StringWithStyle(clr, style))
-	return buf.String()
+	return fs, true
 }
 
 func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr color.Color, dontSetValue bool) {
-	var (
-		typElem  = typ.Elem()
-		valElem  = val.Elem()
-		numField = valElem.NumField()
-	)
-	for i := 0; i < numField; i++ {
-		var (
-			typField          = typElem.Field(i)
-			valField          = valElem.Field(i)
-			tag, isEmpty, err = parseTag(typField.Name, typField.Tag)
-		)
-		if err != nil {
-			flagSet.err = err
-			return
-		}
-		if tag == nil {
-			continue
-		}
-
-		// if `cli` tag is empty and the field is a struct
-		if isEmpty && valField.Kind() == reflect.Struct {
-			var (
-				subObj   = valField.Addr().Interface()
-				subType  = reflect.TypeOf(subObj)
-				subValue = reflect.ValueOf(subObj)
-			)
-			initFlagSet(subType, subValue, flagSet, clr, dontSetValue)
-			if flagSet.err != nil {
-				return
-			}
-			continue
-		}
-		fl, err := newFlag(typField, valField, tag, clr, dontSetValue)
+	schema := getArgvSchema(typ.Elem())
+	if schema.err != nil {
+		flagSet.err = schema.err
+		return
+	}
+	valElem := val.Elem()
+	for _, field := range schema.fields {
+		valField := valElem.FieldByIndex(field.index)
+		fl, err := newFlag(flagSet.app, field, valField, clr, dontSetValue)
 		if flagSet.err = err; err != nil {
 			return
 		}
-		// ignored flag
-		if fl == nil {
-			continue
-		}
 		flagSet.flagSlice = append(flagSet.flagSlice, fl)
 
 		// encode flag value
 		value := ""
 		if fl.isAssigned {
 			if !valField.CanInterface() {
-				flagSet.err = fmt.Errorf("field %s cannot interface", typField.Name)
+				flagSet.err = fmt.Errorf("field %s cannot interface", field.field.Name)
 				return
 			}
 			intf := valField.Interface()
@@ -172,8 +157,7 @@ func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr colo
 			}
 		}
 
-		names := append(fl.tag.shortNames, fl.tag.longNames...)
-		for i, name := range names {
+		for i, name := range fl.tag.names() {
 			if _, ok := flagSet.flagMap[name]; ok {
 				flagSet.err = fmt.Errorf("option %s repeated", clr.Bold(name))
 				return
@@ -282,7 +266,11 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 		if flagSet.err != nil {
 			return
 		}
-		flagSet.readPrompt(os.Stdout, clr)
+		if flagSet.console.wizard {
+			flagSet.readWizard(clr)
+		} else {
+			flagSet.readPrompt(clr)
+		}
 		if flagSet.err != nil {
 			return
 		}
@@ -301,6 +289,9 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 				buff.WriteByte('\n')
 			}
 			fmt.Fprintf(buff, "required parameter %s missing", clr.Bold(fl.name()))
+			if fl.isInputUnavailable {
+				buff.WriteString(" (" + ErrInputUnavailable.Error() + ")")
+			}
 		}
 	}
 	if buff.Len() > 0 && !flagSet.hasForce {
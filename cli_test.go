@@ -690,7 +690,7 @@ func TestHelpCommand(t *testing.T) {
 	help := HelpCommand("help command")
 	root.Register(help)
 	assert.Nil(t, root.RunWith([]string{"help"}, w, nil))
-	assert.Equal(t, w.String(), "Commands:\n\n  help   help command\n")
+	assert.Equal(t, "Usage: root COMMAND\n\nCommands:\n\n  help   help command\n", w.String())
 	assert.Error(t, root.RunWith([]string{"help", "not-found"}, nil, nil))
 }
 
@@ -727,3 +727,60 @@ func TestDecoder(t *testing.T) {
 	assert.Nil(t, flagSet.err)
 	assert.Equal(t, v.D, customT{K1: "string", K2: 2})
 }
+
+func TestExitCode(t *testing.T) {
+	type argT struct {
+		N int `cli:"*n"`
+	}
+	root := &Command{
+		Name:   "root",
+		Argv:   func() interface{} { return new(argT) },
+		NumArg: AtMost(1),
+		Fn: func(ctx *Context) error {
+			switch ctx.Argv().(*argT).N {
+			case 1:
+				return ExitError
+			case 2:
+				return Exit(42, "custom exit")
+			case 3:
+				return fmt.Errorf("failure")
+			}
+			return nil
+		},
+	}
+	root.Register(&Command{Name: "sub", Fn: donothing})
+	w := bytes.NewBufferString("")
+	app := NewApp(root)
+	app.Stdout = w
+	for i, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-n=0"}, ExitOK},
+		{[]string{"-n=1"}, ExitOK},
+		{[]string{"-n=2"}, 42},
+		{[]string{"-n=3"}, ExitFailure},
+		{[]string{}, ExitUsage},
+		{[]string{"-n=x"}, ExitUsage},
+		{[]string{"-n=0", "a", "b"}, ExitUsage},
+		{[]string{"not-found"}, ExitNotFound},
+	} {
+		err := app.Execute(tt.args)
+		assert.Equal(t, tt.code, ExitCodeOf(err), "case %d: %v", i, err)
+	}
+	// Command.Run reports it, too
+	assert.Equal(t, ExitUsage, ExitCodeOf(root.RunWith([]string{"-n=0", "a", "b"}, w, nil)))
+	assert.Equal(t, "custom exit", Exit(42, "custom exit").Error())
+	assert.Equal(t, 3, RunWithArgs(new(argT), []string{"app", "-n=1"}, func(*Context) error {
+		return Exit(3, "")
+	}))
+}
+
+func TestRunPopulatesArgv(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name"`
+	}
+	argv := new(argT)
+	assert.Equal(t, ExitOK, RunWithArgs(argv, []string{"app", "--name=cli"}, donothing))
+	assert.Equal(t, "cli", argv.Name)
+}
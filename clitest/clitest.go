@@ -0,0 +1,204 @@
+// Package clitest runs command trees of github.com/mkideal/cli in process
+// for testing. Prompts, passwords and editors are answered by scripts,
+// so commands which need interactive input can be tested as well.
+package clitest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mkideal/cli"
+)
+
+// Result is the result of running a command
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+
+	// Argv is the parsed argv object of the executed command,
+	// nil if the command not reached
+	Argv interface{}
+
+	// Context is the context of the executed command,
+	// nil if the command not reached
+	Context *cli.Context
+}
+
+// Runner runs a command tree with scripted input
+type Runner struct {
+	root *cli.Command
+
+	answers []string
+	edits   []string
+	env     map[string]string
+	setup   []func(*cli.App)
+}
+
+// New creates a Runner for root command
+func New(root *cli.Command) *Runner {
+	return &Runner{root: root}
+}
+
+// Run runs root command with args and returns the result
+func Run(root *cli.Command, args ...string) *Result {
+	return New(root).Run(args...)
+}
+
+// Answer appends scripted answers for prompts and password fields,
+// each answer answers one prompt in order
+func (r *Runner) Answer(answers ...string) *Runner {
+	r.answers = append(r.answers, answers...)
+	return r
+}
+
+// Edit appends scripted contents for editor launches,
+// each content is written by one launch in order
+func (r *Runner) Edit(contents ...string) *Runner {
+	r.edits = append(r.edits, contents...)
+	return r
+}
+
+// Setenv sets a fake environment variable used to expand default values.
+// Once any variable set, the real environment is invisible to the command.
+func (r *Runner) Setenv(key, value string) *Runner {
+	if r.env == nil {
+		r.env = make(map[string]string)
+	}
+	r.env[key] = value
+	return r
+}
+
+// Setup registers a function which configures the app before running
+func (r *Runner) Setup(fn func(*cli.App)) *Runner {
+	r.setup = append(r.setup, fn)
+	return r
+}
+
+// App creates the app which used to run root command
+func (r *Runner) App() *cli.App {
+	app := cli.NewApp(r.root)
+	app.Color = cli.ColorNever
+	app.Stdin = strings.NewReader(scriptOf(r.answers))
+	app.Stdout = new(bytes.Buffer)
+	app.Stderr = new(bytes.Buffer)
+	app.Editor = func() (string, error) { return "clitest-editor", nil }
+
+	var (
+		mu    sync.Mutex
+		edits = append([]string{}, r.edits...)
+	)
+	app.LaunchEditor = func(editor, filename string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(edits) == 0 {
+			return fmt.Errorf("clitest: unexpected editor launch for %s", filename)
+		}
+		content := edits[0]
+		edits = edits[1:]
+		return ioutil.WriteFile(filename, []byte(content), 0600)
+	}
+	if r.env != nil {
+		env := make(map[string]string, len(r.env))
+		for k, v := range r.env {
+			env[k] = v
+		}
+		app.Getenv = func(key string) string { return env[key] }
+	}
+	for _, fn := range r.setup {
+		fn(app)
+	}
+	return app
+}
+
+// Run runs root command with args and returns the result
+func (r *Runner) Run(args ...string) *Result {
+	var (
+		app    = r.App()
+		result = new(Result)
+	)
+	onContext := app.OnContext
+	app.OnContext = func(ctx *cli.Context) {
+		result.Context = ctx
+		result.Argv = ctx.Argv()
+		if onContext != nil {
+			onContext(ctx)
+		}
+	}
+	exitCode := app.ExitCode
+	app.ExitCode = func(err error) int {
+		result.Err = err
+		if exitCode != nil {
+			return exitCode(err)
+		}
+		return cli.ExitCodeOf(err)
+	}
+	result.ExitCode = app.Run(args)
+	result.Stdout = bufferString(app.Stdout)
+	result.Stderr = bufferString(app.Stderr)
+	return result
+}
+
+// Usage returns usage of the command routed by path without color
+func (r *Runner) Usage(path ...string) string {
+	cmd := r.root.Route(path)
+	if cmd == nil {
+		return ""
+	}
+	return r.App().Usage(cmd)
+}
+
+// Usage returns usage of the command routed by path without color
+func Usage(root *cli.Command, path ...string) string {
+	return New(root).Usage(path...)
+}
+
+func bufferString(w interface{}) string {
+	if s, ok := w.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return ""
+}
+
+func scriptOf(answers []string) string {
+	if len(answers) == 0 {
+		return ""
+	}
+	return strings.Join(answers, "\n") + "\n"
+}
+
+// UpdateGoldenEnv is the environment variable which makes AssertGolden
+// rewrite golden files instead of comparing with them
+const UpdateGoldenEnv = "CLITEST_UPDATE_GOLDEN"
+
+// AssertGolden compares got with content of golden file filename, the file
+// would be written if environment variable CLITEST_UPDATE_GOLDEN is not empty
+func AssertGolden(t testing.TB, filename, got string) bool {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("clitest: %v", err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(got), 0644); err != nil {
+			t.Fatalf("clitest: %v", err)
+		}
+		return true
+	}
+	want, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Errorf("clitest: %v (set %s=1 to create it)", err, UpdateGoldenEnv)
+		return false
+	}
+	if string(want) != got {
+		t.Errorf("clitest: %s mismatch\n--- want\n%s\n--- got\n%s", filename, want, got)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package clitest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mkideal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deployT struct {
+	cli.Helper
+	Env      string `cli:"env" usage:"target environment" dft:"$DEPLOY_ENV"`
+	User     string `cli:"u,user" usage:"deploy as user" prompt:"user"`
+	Password string `pw:"p,password" usage:"password of user" prompt:"password"`
+	Message  string `edit:"m,message" usage:"deploy message"`
+}
+
+func newRoot() *cli.Command {
+	root := &cli.Command{Name: "app", Desc: "app for testing"}
+	root.Register(&cli.Command{
+		Name: "deploy",
+		Desc: "deploy the app",
+		Argv: func() interface{} { return new(deployT) },
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*deployT)
+			if argv.Env == "" {
+				return errors.New("env missing")
+			}
+			ctx.String("deploy to %s by %s:%s, %s", argv.Env, argv.User, argv.Password, argv.Message)
+			return nil
+		},
+	})
+	return root
+}
+
+func TestRun(t *testing.T) {
+	r := Run(newRoot(), "deploy", "--env", "prod", "-u", "root", "-p", "pass", "-m", "msg")
+	require.Nil(t, r.Err)
+	assert.Equal(t, 0, r.ExitCode)
+	assert.Equal(t, "deploy to prod by root:pass, msg", r.Stdout)
+	assert.Equal(t, "", r.Stderr)
+	require.IsType(t, new(deployT), r.Argv)
+	assert.Equal(t, "prod", r.Argv.(*deployT).Env)
+	assert.Equal(t, "deploy", r.Context.Path())
+
+	r = Run(newRoot(), "undefined")
+	assert.Equal(t, cli.ExitNotFound, r.ExitCode)
+	assert.Contains(t, r.Stderr, "command undefined not found")
+	assert.Nil(t, r.Argv)
+
+	r = Run(newRoot(), "deploy", "--undefined")
+	assert.Equal(t, cli.ExitUsage, r.ExitCode)
+	assert.Error(t, r.Err)
+}
+
+func TestScriptedInput(t *testing.T) {
+	r := New(newRoot()).
+		Answer("root", "secret").
+		Edit("hello").
+		Setenv("DEPLOY_ENV", "staging").
+		Run("deploy")
+	require.Nil(t, r.Err)
+	assert.Equal(t, "deploy to staging by root:secret, hello", r.Stdout)
+	assert.Equal(t, "user: password: \n", r.Stderr)
+
+	r = New(newRoot()).Setenv("OTHER", "x").Run("deploy", "-u", "u", "-p", "p", "-m", "m")
+	assert.EqualError(t, r.Err, "env missing")
+	assert.Equal(t, cli.ExitFailure, r.ExitCode)
+
+	r = New(newRoot()).Run("deploy", "--env=prod", "-u", "u", "-p", "p")
+	assert.Error(t, r.Err)
+}
+
+func TestGolden(t *testing.T) {
+	AssertGolden(t, "testdata/deploy.golden", Usage(newRoot(), "deploy"))
+	AssertGolden(t, "testdata/app.golden", New(newRoot()).Usage())
+	assert.Equal(t, "", Usage(newRoot(), "not", "found"))
+}
@@ -25,6 +25,11 @@ func colorSwitch(clr *color.Color, w io.Writer, fds ...uintptr) {
 	}
 }
 
+// isColorEnabled reports whether clr outputs escape sequences
+func isColorEnabled(clr color.Color) bool {
+	return clr.Bold("-") != "-"
+}
+
 // HelpCommandFn implements buildin help command function
 func HelpCommandFn(ctx *Context) error {
 	var (
@@ -40,6 +45,10 @@ func HelpCommandFn(ctx *Context) error {
 		clr   = ctx.Color()
 	)
 	if child == nil {
+		if topic := parent.routeTopic(args); topic != nil {
+			ctx.String(topic.String())
+			return nil
+		}
 		return fmt.Errorf("command %s not found", clr.Yellow(strings.Join(args, " ")))
 	}
 	ctx.String(child.Usage(ctx))
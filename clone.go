@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"reflect"
+)
+
+// cloneArgv returns a deep copy of argv, so that every invocation owns
+// its argv object even if the ArgvFunc returns a shared pointer.
+// Unexported fields are copied shallowly.
+func cloneArgv(argv interface{}) interface{} {
+	if argv == nil {
+		return nil
+	}
+	val := reflect.ValueOf(argv)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return argv
+	}
+	return deepCopy(val, make(map[visitKey]reflect.Value)).Interface()
+}
+
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func deepCopy(val reflect.Value, visited map[visitKey]reflect.Value) reflect.Value {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() {
+			return reflect.Zero(val.Type())
+		}
+		key := visitKey{ptr: val.Pointer(), typ: val.Type()}
+		if dst, ok := visited[key]; ok {
+			return dst
+		}
+		dst := reflect.New(val.Type().Elem())
+		visited[key] = dst
+		dst.Elem().Set(deepCopy(val.Elem(), visited))
+		return dst
+
+	case reflect.Interface:
+		if val.IsNil() {
+			return reflect.Zero(val.Type())
+		}
+		dst := reflect.New(val.Type()).Elem()
+		dst.Set(deepCopy(val.Elem(), visited))
+		return dst
+
+	case reflect.Struct:
+		dst := reflect.New(val.Type()).Elem()
+		dst.Set(val)
+		for i, n := 0, val.NumField(); i < n; i++ {
+			if field := dst.Field(i); field.CanSet() {
+				field.Set(deepCopy(val.Field(i), visited))
+			}
+		}
+		return dst
+
+	case reflect.Array:
+		dst := reflect.New(val.Type()).Elem()
+		for i, n := 0, val.Len(); i < n; i++ {
+			dst.Index(i).Set(deepCopy(val.Index(i), visited))
+		}
+		return dst
+
+	case reflect.Slice:
+		if val.IsNil() {
+			return reflect.Zero(val.Type())
+		}
+		dst := reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+		for i, n := 0, val.Len(); i < n; i++ {
+			dst.Index(i).Set(deepCopy(val.Index(i), visited))
+		}
+		return dst
+
+	case reflect.Map:
+		if val.IsNil() {
+			return reflect.Zero(val.Type())
+		}
+		dst := reflect.MakeMapWithSize(val.Type(), val.Len())
+		iter := val.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(deepCopy(iter.Key(), visited), deepCopy(iter.Value(), visited))
+		}
+		return dst
+	}
+	return val
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneArgv(t *testing.T) {
+	type innerT struct {
+		Value int
+	}
+	type argT struct {
+		Self   *argT
+		Inner  *innerT
+		Slice  []string
+		Map    map[string]int
+		Iface  interface{}
+		hidden []int
+	}
+	src := &argT{
+		Inner:  &innerT{Value: 1},
+		Slice:  []string{"a"},
+		Map:    map[string]int{"a": 1},
+		Iface:  &innerT{Value: 2},
+		hidden: []int{1},
+	}
+	src.Self = src
+
+	dst := cloneArgv(src).(*argT)
+	assert.Equal(t, dst, dst.Self)
+	assert.False(t, src == dst)
+	assert.False(t, src.Inner == dst.Inner)
+	assert.False(t, src.Iface == dst.Iface)
+	assert.Equal(t, 1, dst.Inner.Value)
+	assert.Equal(t, 2, dst.Iface.(*innerT).Value)
+
+	dst.Slice[0] = "b"
+	dst.Map["a"] = 2
+	assert.Equal(t, []string{"a"}, src.Slice)
+	assert.Equal(t, map[string]int{"a": 1}, src.Map)
+	assert.Equal(t, src.hidden, dst.hidden)
+
+	assert.Nil(t, cloneArgv(nil))
+	assert.Equal(t, "argv", cloneArgv("argv"))
+}
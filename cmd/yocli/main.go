@@ -8,7 +8,6 @@ import (
 	"strings"
 	"text/template"
 
-	"github.com/labstack/gommon/color"
 	"github.com/mkideal/cli"
 )
 
@@ -118,20 +117,25 @@ var {{.Name}} = &cli.Command{
 `
 
 func main() {
-	os.Exit(cli.Run(new(argT), func(ctx *cli.Context) error {
-		cli.SetUsageStyle(cli.ManualStyle)
-		argv := ctx.Argv().(*argT)
-		if argv.Help {
-			ctx.WriteUsage()
-			return nil
-		}
-		return run(ctx, argv)
-	}, fmt.Sprintf(`%s used to create a new command for github.com/mkideal/cli
-
-%s: yocli [OPTIONS] COMMAND-NAME
-
-%s:
-	yocli hello
-	yocli -f -s "balabalabala" hello
-	yocli -p balabala hello`, color.Bold("clier"), color.Bold("Usage"), color.Bold("Examples"))))
+	app := cli.NewApp(&cli.Command{
+		Name:     "yocli",
+		Desc:     "yocli used to create a new command for github.com/mkideal/cli",
+		Synopsis: "yocli [OPTIONS] COMMAND-NAME",
+		Examples: []cli.Example{
+			{Cmd: "yocli hello", Desc: "create hello.go with command hello"},
+			{Cmd: `yocli -f -s "balabalabala" hello`, Desc: "overwrite hello.go and set description of the command"},
+			{Cmd: "yocli -p balabala hello", Desc: "set package name of the source file"},
+		},
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *cli.Context) error {
+			argv := ctx.Argv().(*argT)
+			if argv.Help {
+				ctx.WriteUsage()
+				return nil
+			}
+			return run(ctx, argv)
+		},
+	})
+	app.SetUsageStyle(cli.ManualStyle)
+	os.Exit(app.Run(os.Args[1:]))
 }
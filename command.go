@@ -2,17 +2,18 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"reflect"
 	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/labstack/gommon/color"
-	"github.com/mattn/go-colorable"
 )
 
 var commandNameRegexp = regexp.MustCompile("^[a-zA-Z_0-9][a-zA-Z_\\-0-9]*$")
@@ -34,6 +35,10 @@ type (
 
 	// UsageFunc represents custom function of usage
 	UsageFunc func() string
+
+	// Middleware wraps a CommandFunc, it could do something before and
+	// after calling next or not call next at all
+	Middleware func(next CommandFunc) CommandFunc
 )
 
 // ExactN returns a NumCheckFunc which checks if a number is equal to num
@@ -53,6 +58,24 @@ type (
 		Desc    string   // Command abstract
 		Text    string   // Command detail description
 
+		// Synopsis overrides the usage line generated by command path,
+		// flags, children and NumArg, e.g.
+		//
+		//	app deploy [OPTIONS] <target> [ARGS...]
+		Synopsis string
+
+		// Category is heading of the section which the command is listed
+		// in usage of it's parent, commands without category come first
+		Category string
+
+		// Examples are listed in usage, man pages and schema of the command
+		Examples []Example
+
+		// UsageTemplate is the text/template of usage executed with
+		// UsageData, which applies to descendants, too. UsageTemplate of
+		// app or the builtin template of usage style is used if empty
+		UsageTemplate string
+
 		// CanSubRoute indicates whether to allow incomplete subcommand routing
 		// e.g.
 		//
@@ -78,25 +101,62 @@ type (
 		HTTPRouters []string
 		HTTPMethods []string
 
-		// hooks for current command
+		// ArgCompleter completes arguments of the command in shell,
+		// ctx.Args() are arguments typed before the word
+		ArgCompleter Completer
+
+		// Timeout limits running time of the command, Context().Done() is
+		// closed after it. The nearest ancestor's Timeout used if zero
+		Timeout time.Duration
+
+		// hooks wrapped around the command and all it's descendants, hooks
+		// of ancestors are outer ones. The command and OnAfter are skipped
+		// if OnBefore fails, and OnAfter is skipped if the command fails.
+		// Use middlewares for things like recovery.
 		OnBefore func(*Context) error
 		OnAfter  func(*Context) error
 
+		// OnFinally is called after OnAfter whether the command succeeded
+		// or not if OnBefore passed, err is the error returned by the
+		// command or OnAfter, and the error returned by OnFinally replaces
+		// it. It isn't called if the command panics.
+		OnFinally func(ctx *Context, err error) error
+
+		// OnError handles error returned by the command, hooks or
+		// middlewares, including a recovered PanicError. The error
+		// returned by OnError bubbles up to OnError of ancestors, and it's
+		// handled if nil returned
+		OnError func(*Context, error) error
+
 		// hooks for all commands if current command is root command
 		OnRootPrepareError func(error) error
 		OnRootBefore       func(*Context) error
 		OnRootAfter        func(*Context) error
 
+		middlewares []Middleware
+		providers   map[interface{}]Provider
+
 		routersMap map[string]string
 
 		parent   *Command
 		children []*Command
+		topics   []*HelpTopic
 
 		isServer bool
 
-		locker     sync.Mutex // protect following data
-		usage      string
-		usageStyle UsageStyle
+		locker   sync.Mutex // protect following data
+		usage    string
+		usageKey usageCacheKey
+	}
+
+	// usageCacheKey identifies settings which the cached usage generated with
+	usageCacheKey struct {
+		app        *App
+		style      UsageStyle
+		colored    bool
+		width      int
+		template   string
+		noSynopsis bool
 	}
 
 	// CommandTree represents a tree of commands
@@ -120,7 +180,7 @@ func (cmd *Command) Register(child *Command) *Command {
 	if child.parent != nil {
 		panic("command `" + child.Name + "` has been child of `" + child.parent.Name + "`")
 	}
-	if cmd.findChild(child.Name) != nil {
+	if cmd.findChild(child.Name) != nil || cmd.findTopic(child.Name) != nil {
 		panic("repeat register child `" + child.Name + "` for command `" + cmd.Name + "`")
 	}
 	if child.Aliases != nil {
@@ -151,6 +211,65 @@ func (cmd *Command) RegisterTree(forest ...*CommandTree) {
 	}
 }
 
+// Use appends middlewares of the command, which are inherited by all
+// sub-commands. Middlewares are composed from root to leaf, so that the
+// root's first middleware is the outermost one, and hooks run inside them.
+func (cmd *Command) Use(middlewares ...Middleware) *Command {
+	cmd.middlewares = append(cmd.middlewares, middlewares...)
+	return cmd
+}
+
+// chain wraps fn with hooks and middlewares of the command and it's
+// ancestors, root is the command which runs
+func (cmd *Command) chain(root *Command, fn CommandFunc) CommandFunc {
+	fn = hook(root.OnRootBefore, root.OnRootAfter, nil)(fn)
+	for c := cmd; c != nil; c = c.parent {
+		fn = hook(c.OnBefore, c.OnAfter, c.OnFinally)(fn)
+	}
+	for c := cmd; c != nil; c = c.parent {
+		for i := len(c.middlewares) - 1; i >= 0; i-- {
+			fn = c.middlewares[i](fn)
+		}
+	}
+	return fn
+}
+
+// hook returns a middleware which calls before and after around next,
+// next and after are skipped if an error returned. finally is called at
+// last if before passed.
+func hook(before, after CommandFunc, finally func(*Context, error) error) Middleware {
+	return func(next CommandFunc) CommandFunc {
+		if before == nil && after == nil && finally == nil {
+			return next
+		}
+		return func(ctx *Context) error {
+			if before != nil {
+				if err := before(ctx); err != nil {
+					return err
+				}
+			}
+			err := next(ctx)
+			if err == nil && after != nil {
+				err = after(ctx)
+			}
+			if finally != nil {
+				err = finally(ctx, err)
+			}
+			return err
+		}
+	}
+}
+
+// timeout returns Timeout of the command or it's nearest ancestor
+func (cmd *Command) timeout() time.Duration {
+	for c := cmd; c != nil; c = c.parent {
+		if c.Timeout > 0 {
+			return c.Timeout
+		}
+	}
+	return 0
+}
+
 // Parent returns command's parent
 func (cmd *Command) Parent() *Command {
 	return cmd.parent
@@ -178,20 +297,100 @@ func (cmd *Command) Run(args []string) error {
 
 // RunWith runs the command with args and writer,httpMethods
 func (cmd *Command) RunWith(args []string, writer io.Writer, resp http.ResponseWriter, httpMethods ...string) error {
-	fds := []uintptr{}
-	if writer == nil {
-		writer = colorable.NewColorableStdout()
-		fds = append(fds, os.Stdout.Fd())
+	return cmd.run(&runOptions{
+		stdout:      writer,
+		resp:        resp,
+		httpMethods: httpMethods,
+	}, args)
+}
+
+// RunWithContext is similar to RunWith, but Context().Done() of the
+// invocation is closed if parent done
+func (cmd *Command) RunWithContext(parent context.Context, args []string, writer io.Writer, resp http.ResponseWriter, httpMethods ...string) error {
+	return cmd.run(&runOptions{
+		ctx:         parent,
+		stdout:      writer,
+		resp:        resp,
+		httpMethods: httpMethods,
+	}, args)
+}
+
+// RunWithIO runs the command with args and standard streams,
+// streams of app used if nil
+func (cmd *Command) RunWithIO(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return cmd.run(&runOptions{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}, args)
+}
+
+// runOptions holds settings of an invocation
+type runOptions struct {
+	app         *App
+	ctx         context.Context
+	req         *http.Request
+	stdin       io.Reader
+	stdout      io.Writer
+	stderr      io.Writer
+	resp        http.ResponseWriter
+	httpMethods []string
+	noInput     bool
+	interactive bool
+	width       int
+	signals     bool // whether to handle signals
+}
+
+func (cmd *Command) run(opts *runOptions, args []string) error {
+	opts.app = appOrDefault(opts.app)
+	writer, fds := opts.app.output(opts.stdout)
+	opts.stdout = writer
+	if opts.stdin == nil {
+		opts.stdin = opts.app.stdin()
+	}
+	if opts.stderr == nil {
+		opts.stderr = opts.app.stderr()
 	}
 	clr := color.Color{}
-	colorSwitch(&clr, writer, fds...)
+	opts.app.colorize(&clr, writer, fds...)
+	if opts.resp == nil {
+		opts.width = opts.app.width(writer, fds...)
+	}
+	if opts.ctx == nil {
+		opts.ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	opts.ctx, cancel = context.WithCancel(opts.ctx)
+	defer cancel()
+	if opts.resp == nil && opts.signals {
+		defer handleSignals(cancel)()
+	}
+	if opts.resp == nil && len(args) > 0 && args[0] == CompleteCommandName && cmd.findChild(CompleteCommandName) == nil {
+		return cmd.writeCompletions(opts, args[1:])
+	}
+	if opts.resp == nil {
+		var noInput, interactive bool
+		args, noInput = cmd.extractSwitch(args, NoInputFlag)
+		args, interactive = cmd.extractSwitch(args, InteractiveFlag)
+		opts.noInput = opts.noInput || noInput
+		opts.interactive = opts.interactive || interactive
+		if rest, helpJSON := cmd.extractSwitch(args, HelpJSONFlag); helpJSON {
+			return cmd.writeHelpJSON(opts, rest)
+		}
+	}
 
 	var ctx *Context
 	var suggestion string
-	ctx, suggestion, err := cmd.prepare(clr, args, writer, resp, httpMethods...)
+	ctx, suggestion, err := cmd.prepare(clr, args, opts)
+	if ctx != nil && ctx.cancel != nil {
+		defer ctx.cancel()
+	}
 	if err == ExitError {
 		return nil
 	}
+	if isSilentError(err) {
+		return err
+	}
 
 	if err != nil {
 		if cmd.OnRootPrepareError != nil {
@@ -203,28 +402,45 @@ func (cmd *Command) RunWith(args []string, writer io.Writer, resp http.ResponseW
 		return nil
 	}
 
-	if ctx.command.NoHook {
-		return ctx.command.Fn(ctx)
+	if opts.app.OnContext != nil {
+		opts.app.OnContext(ctx)
 	}
 
-	funcs := []func(*Context) error{
-		ctx.command.OnBefore,
-		cmd.OnRootBefore,
-		ctx.command.Fn,
-		cmd.OnRootAfter,
-		ctx.command.OnAfter,
+	fn := ctx.command.Fn
+	if fn == nil {
+		fn = func(*Context) error { return nil }
 	}
-	for _, f := range funcs {
-		if f != nil {
-			if err := f(ctx); err != nil {
-				if err == ExitError {
-					return nil
-				}
-				return err
-			}
+	if !ctx.command.NoHook {
+		fn = ctx.command.chain(cmd, fn)
+	}
+	err = safeCall(fn, ctx)
+	if err != nil && err != ExitError {
+		err = ctx.command.handleError(ctx, err)
+	}
+	if closeErr := ctx.closeResources(); err == nil {
+		err = closeErr
+	}
+	if err == ExitError {
+		return nil
+	}
+	return err
+}
+
+// safeCall calls fn and recovers panic into a PanicError
+func safeCall(fn CommandFunc, ctx *Context) (err error) {
+	defer catchPanic(&err)
+	return fn(ctx)
+}
+
+// handleError passes err to OnError of the command and it's ancestors in
+// turn until it's handled
+func (cmd *Command) handleError(ctx *Context, err error) error {
+	for c := cmd; c != nil && err != nil; c = c.parent {
+		if c.OnError != nil {
+			err = c.OnError(ctx, err)
 		}
 	}
-	return nil
+	return err
 }
 
 func isEmptyArgvList(argvList []interface{}) bool {
@@ -258,7 +474,8 @@ func (cmd *Command) argvList() []interface{} {
 	return argvList
 }
 
-func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, resp http.ResponseWriter, httpMethods ...string) (ctx *Context, suggestion string, err error) {
+func (cmd *Command) prepare(clr color.Color, args []string, opts *runOptions) (ctx *Context, suggestion string, err error) {
+	httpMethods := opts.httpMethods
 	// split args
 	router := []string{}
 	for _, arg := range args {
@@ -308,25 +525,42 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 		return
 	}
 
-	// create argvList
+	// create argvList, every HTTP request must own its argv objects
 	argvList := child.argvList()
+	if opts.resp != nil {
+		for i := range argvList {
+			argvList[i] = cloneArgv(argvList[i])
+		}
+	}
 
 	// create Context
+	if timeout := child.timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		opts.ctx, cancel = context.WithTimeout(opts.ctx, timeout)
+		defer func() {
+			if ctx != nil {
+				ctx.cancel = cancel
+			} else {
+				cancel()
+			}
+		}()
+	}
 	path = child.Path()
-	ctx, err = newContext(path, router[:end], args[end:], argvList, clr)
+	ctx, err = newContext(opts, path, router[:end], args[end:], argvList, clr)
 	ctx.command = child
-	ctx.writer = writer
 	if !ctx.flagSet.hasForce {
 		if !child.checkNumOption(ctx.NOpt()) || !ctx.command.checkNumArg(ctx.NArg()) {
 			ctx.WriteUsage()
-			err = ExitError
+			err = errUsageShown
 			return
 		}
 	}
 	if err != nil {
+		err = usageError{err}
 		return
 	}
-	ctx.HTTPResponse = resp
+	ctx.HTTPRequest = opts.req
+	ctx.HTTPResponse = opts.resp
 
 	// auto help
 	for _, argv := range argvList {
@@ -349,8 +583,11 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 			// validate argv if argv implements interface Validator
 			if argv != nil {
 				if validator, ok := argv.(Validator); ok {
-					err = validator.Validate(ctx)
+					err = safeCall(validator.Validate, ctx)
 					if err != nil {
+						if _, isPanic := err.(*PanicError); !isPanic {
+							err = usageError{err}
+						}
 						return
 					}
 				}
@@ -361,6 +598,27 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 	return
 }
 
+// hasFlag reports whether argv objects of command define flag name
+func (cmd *Command) hasFlag(name string) bool {
+	for _, argv := range cmd.argvList() {
+		if argv == nil {
+			continue
+		}
+		typ := reflect.TypeOf(argv)
+		if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		for _, field := range getArgvSchema(typ.Elem()).fields {
+			for _, s := range field.tag.names() {
+				if s == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func (cmd *Command) checkNumArg(num int) bool {
 	return cmd.NumArg == nil || cmd.NumArg(num)
 }
@@ -379,41 +637,35 @@ func (cmd *Command) Usage(ctx *Context) string {
 
 func (cmd *Command) defaultUsageFn(ctx *Context) string {
 	var (
-		style = GetUsageStyle()
-		clr   = *(ctx.Color())
+		app = ctx.App()
+		clr = *(ctx.Color())
+		key = usageCacheKey{
+			app:        app,
+			style:      app.UsageStyle(),
+			colored:    isColorEnabled(clr),
+			width:      ctx.width,
+			noSynopsis: app.NoSynopsis,
+		}
+		style = key.style
 	)
+	key.template = cmd.usageTemplateText(app, style)
 
 	// get usage form cache
 	cmd.locker.Lock()
 	tmpUsage := cmd.usage
-	usageStyle := cmd.usageStyle
+	usageKey := cmd.usageKey
 	cmd.locker.Unlock()
-	if tmpUsage != "" && usageStyle == style {
+	if tmpUsage != "" && usageKey == key {
 		return tmpUsage
 	}
 
-	buff := bytes.NewBufferString("")
-	if cmd.Desc != "" {
-		fmt.Fprintf(buff, "%s\n\n", cmd.Desc)
-	}
-	if cmd.Text != "" {
-		fmt.Fprintf(buff, "%s\n\n", cmd.Text)
-	}
-	argvList := cmd.argvList()
-	isEmpty := isEmptyArgvList(argvList)
-	if !isEmpty {
-		fmt.Fprintf(buff, "%s:\n\n%s", clr.Bold("Options"), usage(argvList, clr, style))
-	}
-	if cmd.children != nil && len(cmd.children) > 0 {
-		if !isEmpty {
-			buff.WriteByte('\n')
-		}
-		fmt.Fprintf(buff, "%s:\n\n%v", clr.Bold("Commands"), cmd.ChildrenDescriptions("  ", "   "))
+	tmpUsage, err := cmd.renderUsage(app, key.template, clr, style, key.width)
+	if err != nil {
+		return fmt.Sprintf("%s usage template: %v\n", clr.Red("ERR!"), err)
 	}
-	tmpUsage = buff.String()
 	cmd.locker.Lock()
 	cmd.usage = tmpUsage
-	cmd.usageStyle = style
+	cmd.usageKey = key
 	cmd.locker.Unlock()
 	return tmpUsage
 }
@@ -507,18 +759,30 @@ func (cmd *Command) ListChildren() []string {
 
 // ChildrenDescriptions returns all children's brief infos by one string
 func (cmd *Command) ChildrenDescriptions(prefix, indent string) string {
+	return cmd.childrenDescriptions(prefix, indent, 0)
+}
+
+// childrenDescriptions is similar to ChildrenDescriptions, but descriptions
+// are wrapped to width under the column of descriptions if width isn't 0
+func (cmd *Command) childrenDescriptions(prefix, indent string, width int) string {
+	return cmd.childrenDescriptionsOf(cmd.children, prefix, indent, width)
+}
+
+// childrenDescriptionsOf returns descriptions of the children, which are
+// aligned with all children of the command
+func (cmd *Command) childrenDescriptionsOf(children []*Command, prefix, indent string, width int) string {
 	if cmd.nochild() {
 		return ""
 	}
 	buff := bytes.NewBufferString("")
 	length := 0
 	for _, child := range cmd.children {
-		if len(child.Name) > length {
-			length = len(child.Name)
+		if l := displayWidth(child.Name); l > length {
+			length = l
 		}
 	}
-	format := fmt.Sprintf("%s%%-%ds%s%%s%%s\n", prefix, length, indent)
-	for _, child := range cmd.children {
+	column := displayWidth(prefix) + length + displayWidth(indent)
+	for _, child := range children {
 		aliases := ""
 		if child.Aliases != nil && len(child.Aliases) > 0 {
 			aliasesBuff := bytes.NewBufferString(" (aliases ")
@@ -526,11 +790,40 @@ func (cmd *Command) ChildrenDescriptions(prefix, indent string) string {
 			aliasesBuff.WriteString(")")
 			aliases = aliasesBuff.String()
 		}
-		fmt.Fprintf(buff, format, child.Name, child.Desc, aliases)
+		desc := wrapIndent(child.Desc+aliases, width, column)
+		fmt.Fprintf(buff, "%s%s%s%s\n", prefix, padRight(child.Name, length), indent, desc)
 	}
 	return buff.String()
 }
 
+// commandCategory is a section of children in usage
+type commandCategory struct {
+	name     string
+	commands []*Command
+}
+
+// categories splits children by Category, children without category come
+// first and then categories in order of registration
+func (cmd *Command) categories() []commandCategory {
+	var (
+		categories = []commandCategory{{}}
+		indexes    = map[string]int{"": 0}
+	)
+	for _, child := range cmd.children {
+		i, ok := indexes[child.Category]
+		if !ok {
+			i = len(categories)
+			indexes[child.Category] = i
+			categories = append(categories, commandCategory{name: child.Category})
+		}
+		categories[i].commands = append(categories[i].commands, child)
+	}
+	if len(categories[0].commands) == 0 {
+		categories = categories[1:]
+	}
+	return categories
+}
+
 func (cmd *Command) nochild() bool {
 	return cmd.children == nil || len(cmd.children) == 0
 }
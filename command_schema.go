@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// HelpJSONFlag is the global switch which prints schema of the command
+// routed in JSON instead of running it
+const HelpJSONFlag = "--help-json"
+
+// numArgProbes is the bound of numbers probed to find range of NumArg
+const numArgProbes = 64
+
+type (
+	// CommandSchema is a JSON-serializable description of a command and
+	// it's descendants, hidden commands are omitted
+	CommandSchema struct {
+		Name        string           `json:"name"`
+		Path        string           `json:"path"`
+		Aliases     []string         `json:"aliases,omitempty"`
+		Category    string           `json:"category,omitempty"`
+		Desc        string           `json:"desc,omitempty"`
+		Text        string           `json:"text,omitempty"`
+		HTTPRouters []string         `json:"httpRouters,omitempty"`
+		HTTPMethods []string         `json:"httpMethods,omitempty"`
+		Args        *ArgsSchema      `json:"args,omitempty"`
+		Flags       []*FlagSchema    `json:"flags,omitempty"`
+		Examples    []Example        `json:"examples,omitempty"`
+		Topics      []*HelpTopic     `json:"topics,omitempty"`
+		Commands    []*CommandSchema `json:"commands,omitempty"`
+	}
+
+	// ArgsSchema describes number of arguments accepted by NumArg, which is
+	// probed by calling NumArg. Max is -1 if unbounded.
+	ArgsSchema struct {
+		Min int `json:"min"`
+		Max int `json:"max"`
+	}
+
+	// FlagSchema describes a flag, including flags of global ancestors
+	FlagSchema struct {
+		Names     []string `json:"names"`
+		Type      string   `json:"type"`
+		ValueName string   `json:"valueName,omitempty"`
+		Default   string   `json:"default,omitempty"`
+		Usage     string   `json:"usage,omitempty"`
+		Choices   []string `json:"choices,omitempty"`
+		Required  bool     `json:"required,omitempty"`
+		Force     bool     `json:"force,omitempty"`
+		Password  bool     `json:"password,omitempty"`
+		Edit      bool     `json:"edit,omitempty"`
+		Prompt    string   `json:"prompt,omitempty"`
+		Group     string   `json:"group,omitempty"`
+	}
+)
+
+// Schema returns description of the command and it's descendants
+func (cmd *Command) Schema() *CommandSchema {
+	s := &CommandSchema{
+		Name:        cmd.Name,
+		Path:        cmd.fullPath(),
+		Aliases:     cmd.Aliases,
+		Category:    cmd.Category,
+		Desc:        cmd.Desc,
+		Text:        cmd.docText(),
+		HTTPRouters: cmd.HTTPRouters,
+		HTTPMethods: cmd.HTTPMethods,
+		Args:        probeNumArg(cmd.NumArg),
+		Examples:    cmd.Examples,
+		Topics:      cmd.topics,
+	}
+	fs, _ := usageFlags(cmd.argvList())
+	for _, fl := range fs {
+		s.Flags = append(s.Flags, &FlagSchema{
+			Names:     fl.tag.names(),
+			Type:      fl.field.Type.String(),
+			ValueName: fl.tag.name,
+			Default:   fl.tag.dft,
+			Usage:     fl.tag.usage,
+			Choices:   fl.tag.choices,
+			Required:  fl.tag.isRequired,
+			Force:     fl.tag.isForce,
+			Password:  fl.tag.isPassword,
+			Edit:      fl.tag.isEdit,
+			Prompt:    fl.tag.prompt,
+			Group:     fl.tag.group,
+		})
+	}
+	for _, child := range cmd.docChildren() {
+		s.Commands = append(s.Commands, child.Schema())
+	}
+	return s
+}
+
+// WriteSchema writes schema of the command in indented JSON
+func (cmd *Command) WriteSchema(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(cmd.Schema())
+}
+
+// probeNumArg finds range of numbers accepted by check, nil returned if
+// check is nil
+func probeNumArg(check NumCheckFunc) *ArgsSchema {
+	if check == nil {
+		return nil
+	}
+	s := &ArgsSchema{Min: -1, Max: -1}
+	for n := 0; n <= numArgProbes; n++ {
+		if check(n) {
+			if s.Min < 0 {
+				s.Min = n
+			}
+			s.Max = n
+		}
+	}
+	if s.Min < 0 {
+		s.Min = numArgProbes + 1
+	}
+	if check(numArgProbes + 1) {
+		s.Max = -1
+	}
+	return s
+}
+
+// writeHelpJSON writes schema of the command routed by words of args
+func (cmd *Command) writeHelpJSON(opts *runOptions, args []string) error {
+	return cmd.routeSkippingFlags(args).WriteSchema(opts.stdout)
+}
+
+// routeSkippingFlags routes words of args like SubRoute, flags of commands
+// on the way and their values are skipped, e.g. `--verbose deploy`
+func (cmd *Command) routeSkippingFlags(args []string) *Command {
+	cur := cmd
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == dashTwo {
+			break
+		}
+		if strings.HasPrefix(arg, dashOne) {
+			if strings.Contains(arg, "=") {
+				continue
+			}
+			fs, _ := usageFlags(cur.argvList())
+		flags:
+			for _, fl := range fs {
+				for _, name := range fl.tag.names() {
+					if name == arg {
+						if fl.needValue() {
+							i++
+						}
+						break flags
+					}
+				}
+			}
+			continue
+		}
+		child := cur.findChild(arg)
+		if child == nil {
+			break
+		}
+		cur = child
+	}
+	return cur
+}
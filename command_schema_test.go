@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandSchema(t *testing.T) {
+	root := newDocsTree()
+	deploy := root.Route([]string{"deploy"})
+	deploy.NumArg = AtLeast(1)
+	deploy.HTTPRouters = []string{"/v1/deploy"}
+	deploy.HTTPMethods = []string{"POST"}
+
+	s := root.Schema()
+	assert.Equal(t, "app", s.Path)
+	assert.Nil(t, s.Args)
+	require.Len(t, s.Commands, 2)
+	assert.Equal(t, &FlagSchema{Names: []string{"-h", "--help"}, Type: "bool", Usage: "display help information", Force: true}, s.Flags[0])
+
+	s = s.Commands[0]
+	assert.Equal(t, "app deploy", s.Path)
+	assert.Equal(t, []string{"dp"}, s.Aliases)
+	assert.Equal(t, []string{"/v1/deploy"}, s.HTTPRouters)
+	assert.Equal(t, &ArgsSchema{Min: 1, Max: -1}, s.Args)
+	require.Len(t, s.Flags, 5)
+	assert.Equal(t, &FlagSchema{Names: []string{"-e", "--env"}, Type: "string", Usage: "environment", Choices: []string{"dev", "prod"}, Required: true}, s.Flags[1])
+	assert.Equal(t, &FlagSchema{Names: []string{"--dir"}, Type: "string", Default: "$HOME/app", Usage: "working directory"}, s.Flags[2])
+	assert.Equal(t, &FlagSchema{Names: []string{"--tag"}, Type: "[]string", ValueName: "TAG", Usage: "tags | labels"}, s.Flags[3])
+
+	assert.Equal(t, &ArgsSchema{Min: 2, Max: 2}, probeNumArg(ExactN(2)))
+	assert.Equal(t, &ArgsSchema{Min: 0, Max: 3}, probeNumArg(AtMost(3)))
+
+	// the builtin switch
+	stdout := new(bytes.Buffer)
+	require.Nil(t, root.RunWith([]string{"deploy", "--env", "dev", HelpJSONFlag}, stdout, nil))
+	var got CommandSchema
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &got))
+	assert.Equal(t, "app deploy", got.Path)
+	assert.Equal(t, &ArgsSchema{Min: 1, Max: -1}, got.Args)
+	assert.Contains(t, stdout.String(), "\n  \"name\": \"deploy\",\n")
+
+	// global flags before the sub-command are skipped
+	type globalT struct {
+		Verbose bool   `cli:"v,verbose"`
+		Profile string `cli:"profile"`
+	}
+	app := &Command{Name: "app", Global: true, Argv: func() interface{} { return new(globalT) }, Fn: donothing}
+	app.Register(&Command{Name: "deploy", Fn: donothing})
+	for _, args := range [][]string{
+		{"--verbose", "deploy", HelpJSONFlag},
+		{"--profile", "dev", "-v", "deploy", HelpJSONFlag},
+		{"--profile=dev", "deploy", HelpJSONFlag},
+		{HelpJSONFlag, "deploy"},
+	} {
+		stdout.Reset()
+		require.Nil(t, app.RunWith(args, stdout, nil))
+		require.Nil(t, json.Unmarshal(stdout.Bytes(), &got))
+		assert.Equal(t, "app deploy", got.Path, "%q", args)
+	}
+	// value of a flag isn't routed
+	stdout.Reset()
+	require.Nil(t, app.RunWith([]string{"--profile", "deploy", HelpJSONFlag}, stdout, nil))
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &got))
+	assert.Equal(t, "app", got.Path)
+}
@@ -1,8 +1,14 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -212,3 +218,284 @@ func TestCommandMisc(t *testing.T) {
 
 	assert.Equal(t, root.Suggestions("su"), []string{"sub"})
 }
+
+func TestCommandContext(t *testing.T) {
+	wait := func(ctx *Context) error {
+		select {
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		case <-time.After(time.Second):
+			return fmt.Errorf("not done")
+		}
+	}
+	root := &Command{Name: "root", Timeout: 10 * time.Millisecond}
+	root.Register(&Command{Name: "sub", Fn: wait})
+	root.Register(&Command{Name: "fast", Fn: wait, Timeout: time.Millisecond})
+
+	// timeout of nearest ancestor
+	assert.Equal(t, context.DeadlineExceeded, root.Run([]string{"sub"}))
+	assert.Equal(t, context.DeadlineExceeded, root.Run([]string{"fast"}))
+
+	// parent context
+	root.Timeout = 0
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, root.RunWithContext(parent, []string{"sub"}, nil, nil))
+
+	// the first signal cancels context in App.Run
+	process, err := os.FindProcess(os.Getpid())
+	require.Nil(t, err)
+	var signalErr error
+	root.Register(&Command{Name: "signal", Fn: func(ctx *Context) error {
+		if err := process.Signal(os.Interrupt); err != nil {
+			t.Skip("signal unsupported:", err)
+		}
+		signalErr = wait(ctx)
+		return signalErr
+	}})
+	app := NewApp(root)
+	app.Stderr = new(bytes.Buffer)
+	assert.Equal(t, ExitFailure, app.Run([]string{"signal"}))
+	assert.Equal(t, context.Canceled, signalErr)
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) CommandFunc {
+		return func(*Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	wrap := func(name string) Middleware {
+		return func(next CommandFunc) CommandFunc {
+			return func(ctx *Context) error {
+				calls = append(calls, name+">")
+				err := next(ctx)
+				calls = append(calls, "<"+name)
+				return err
+			}
+		}
+	}
+	root := &Command{
+		Name:         "root",
+		OnRootBefore: record("root-before"),
+		OnRootAfter:  record("root-after"),
+	}
+	root.Use(wrap("a"), wrap("b"))
+	parent := root.Register(&Command{Name: "parent"}).Use(wrap("c"))
+	child := parent.Register(&Command{
+		Name:     "child",
+		OnBefore: record("before"),
+		OnAfter:  record("after"),
+		Fn:       record("fn"),
+	})
+	child.Use(wrap("d"))
+
+	assert.Nil(t, root.Run([]string{"parent", "child"}))
+	assert.Equal(t, []string{
+		"a>", "b>", "c>", "d>",
+		"before", "root-before", "fn", "root-after", "after",
+		"<d", "<c", "<b", "<a",
+	}, calls)
+
+	// middlewares wrap around failed commands, after hooks are skipped
+	calls = nil
+	child.Fn = func(*Context) error { return fmt.Errorf("failed") }
+	assert.EqualError(t, root.Run([]string{"parent", "child"}), "failed")
+	assert.Equal(t, []string{"a>", "b>", "c>", "d>", "before", "root-before", "<d", "<c", "<b", "<a"}, calls)
+
+	// a middleware could stop the chain
+	calls = nil
+	root.Use(func(next CommandFunc) CommandFunc {
+		return func(*Context) error { return ExitError }
+	})
+	assert.Nil(t, root.Run([]string{"parent", "child"}))
+	assert.Equal(t, []string{"a>", "b>", "<b", "<a"}, calls)
+
+	// NoHook skips both hooks and middlewares
+	calls = nil
+	child.NoHook = true
+	child.Fn = record("fn")
+	assert.Nil(t, root.Run([]string{"parent", "child"}))
+	assert.Equal(t, []string{"fn"}, calls)
+}
+
+func TestHooksOfAncestors(t *testing.T) {
+	var calls []string
+	record := func(name string) CommandFunc {
+		return func(*Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	finally := func(name string) func(*Context, error) error {
+		return func(_ *Context, err error) error {
+			calls = append(calls, fmt.Sprintf("%s(%v)", name, err))
+			return err
+		}
+	}
+	root := &Command{
+		Name:      "root",
+		OnBefore:  record("root-before"),
+		OnAfter:   record("root-after"),
+		OnFinally: finally("root-finally"),
+	}
+	parent := root.Register(&Command{
+		Name:     "parent",
+		OnBefore: record("parent-before"),
+		OnAfter:  record("parent-after"),
+	})
+	child := parent.Register(&Command{
+		Name:      "child",
+		OnBefore:  record("child-before"),
+		OnAfter:   record("child-after"),
+		OnFinally: finally("child-finally"),
+		Fn:        record("fn"),
+	})
+
+	assert.Nil(t, root.Run([]string{"parent", "child"}))
+	assert.Equal(t, []string{
+		"root-before", "parent-before", "child-before",
+		"fn",
+		"child-after", "child-finally(<nil>)",
+		"parent-after",
+		"root-after", "root-finally(<nil>)",
+	}, calls)
+
+	// OnFinally runs even if the command fails
+	calls = nil
+	child.Fn = func(*Context) error { return fmt.Errorf("failed") }
+	assert.EqualError(t, root.Run([]string{"parent", "child"}), "failed")
+	assert.Equal(t, []string{
+		"root-before", "parent-before", "child-before",
+		"child-finally(failed)", "root-finally(failed)",
+	}, calls)
+
+	// OnFinally could replace the error
+	calls = nil
+	child.OnFinally = func(*Context, error) error { return nil }
+	assert.Nil(t, root.Run([]string{"parent", "child"}))
+	assert.Equal(t, []string{
+		"root-before", "parent-before", "child-before",
+		"parent-after", "root-after", "root-finally(<nil>)",
+	}, calls)
+
+	// a failed OnBefore skips hooks inside it
+	calls = nil
+	parent.OnBefore = func(*Context) error { return fmt.Errorf("denied") }
+	assert.EqualError(t, root.Run([]string{"parent", "child"}), "denied")
+	assert.Equal(t, []string{"root-before", "root-finally(denied)"}, calls)
+}
+
+type panicValidatorT struct{}
+
+func (*panicValidatorT) Validate(*Context) error { panic("validate") }
+
+func TestPanicAndOnError(t *testing.T) {
+	var handled []string
+	root := &Command{
+		Name: "root",
+		OnError: func(ctx *Context, err error) error {
+			handled = append(handled, "root: "+err.Error())
+			if err.Error() == "sub: unhandled" {
+				return err
+			}
+			return nil
+		},
+	}
+	sub := root.Register(&Command{
+		Name: "sub",
+		Fn:   func(*Context) error { panic("boom") },
+		OnError: func(ctx *Context, err error) error {
+			var pe *PanicError
+			if assert.True(t, errors.As(err, &pe)) {
+				assert.Equal(t, "boom", pe.Value)
+				assert.Contains(t, string(pe.Stack), "TestPanicAndOnError")
+			}
+			handled = append(handled, "sub: "+err.Error())
+			return fmt.Errorf("sub: %v", err)
+		},
+	})
+
+	// errors bubble up until handled
+	assert.Nil(t, root.Run([]string{"sub"}))
+	assert.Equal(t, []string{"sub: panic: boom", "root: sub: panic: boom"}, handled)
+
+	handled = nil
+	sub.Fn = func(*Context) error { return fmt.Errorf("unhandled") }
+	sub.OnError = func(ctx *Context, err error) error { return fmt.Errorf("sub: %v", err) }
+	assert.EqualError(t, root.Run([]string{"sub"}), "sub: unhandled")
+	assert.Equal(t, []string{"root: sub: unhandled"}, handled)
+
+	// panics in validators
+	sub.Argv = func() interface{} { return new(panicValidatorT) }
+	err := root.Run([]string{"sub"})
+	var pe *PanicError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, "validate", pe.Value)
+	assert.Equal(t, ExitFailure, ExitCodeOf(err))
+
+	// stack printed in debug mode only
+	for _, debug := range []bool{false, true} {
+		stderr := new(bytes.Buffer)
+		app := NewApp(&Command{Name: "app", Fn: func(*Context) error { panic("boom") }})
+		app.Stdout, app.Stderr, app.Debug = new(bytes.Buffer), stderr, debug
+		assert.Equal(t, ExitFailure, app.Run(nil))
+		assert.True(t, strings.HasPrefix(stderr.String(), "panic: boom\n"))
+		assert.Equal(t, debug, strings.Contains(stderr.String(), "goroutine"))
+	}
+}
+
+func TestGroupsAndCategories(t *testing.T) {
+	type proxyT struct {
+		Proxy   string `cli:"proxy" usage:"proxy address"`
+		NoProxy string `cli:"no-proxy" usage:"hosts not proxied" group:"Other"`
+	}
+	type argT struct {
+		Verbose bool   `cli:"v" usage:"verbose output"`
+		Host    string `cli:"host" usage:"host to connect" group:"Networking"`
+		proxyT  `group:"Networking"`
+		Format  string `cli:"format" usage:"output format" group:"Output"`
+	}
+	root := &Command{
+		Name: "app",
+		Argv: func() interface{} { return new(argT) },
+	}
+	root.Register(&Command{Name: "get", Desc: "get resources", Category: "Basic"})
+	root.Register(&Command{Name: "version", Desc: "show version"})
+	root.Register(&Command{Name: "delete", Desc: "delete resources", Category: "Basic"})
+
+	assert.Equal(t, `Usage: app [OPTIONS] COMMAND
+
+Options:
+
+  -v               verbose output
+
+Networking:
+
+      --host       host to connect
+      --proxy      proxy address
+
+Other:
+
+      --no-proxy   hosts not proxied
+
+Output:
+
+      --format     output format
+
+Commands:
+
+  version   show version
+
+Basic:
+
+  get       get resources
+  delete    delete resources
+`, NewApp(root).Usage(root))
+
+	s := root.Schema()
+	assert.Equal(t, "Networking", s.Flags[2].Group)
+	assert.Equal(t, "Basic", s.Commands[0].Category)
+}
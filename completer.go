@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Completer completes a flag value or an argument. ctx carries flags and
+// arguments typed before the word, so that earlier flags could narrow
+// candidates. Candidates not starting with word are dropped.
+//
+// A flag is completed by it's type if the type implements Completer, or by
+// tag `complete`:
+//
+//	complete:"files"        // files and directories
+//	complete:"files:*.yaml" // files matching patterns separated by commas
+//	complete:"dirs"         // directories
+//	complete:"func:name"    // completer registered by RegisterCompleter
+//
+// An unknown or unregistered completer is reported to stderr of app while
+// completing, and the value isn't completed.
+type Completer interface {
+	Complete(ctx *Context, word string) []string
+}
+
+// CompleterFunc is an adapter to allow the use of ordinary functions as
+// Completer
+type CompleterFunc func(ctx *Context, word string) []string
+
+// Complete calls f(ctx, word)
+func (f CompleterFunc) Complete(ctx *Context, word string) []string {
+	return f(ctx, word)
+}
+
+var completerType = reflect.TypeOf((*Completer)(nil)).Elem()
+
+// RegisterCompleter registers Completer by name for default app,
+// completers of default app are visible to all apps
+func RegisterCompleter(name string, completer Completer) {
+	defaultApp.RegisterCompleter(name, completer)
+}
+
+// parseCompleter creates Completer by value of tag `complete`
+func parseCompleter(app *App, s string) (Completer, error) {
+	kind, arg := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		kind, arg = s[:i], s[i+1:]
+	}
+	switch kind {
+	case "files":
+		var patterns []string
+		if arg != "" {
+			patterns = strings.Split(arg, ",")
+		}
+		return fileCompleter{patterns: patterns}, nil
+	case "dirs":
+		return fileCompleter{dirsOnly: true}, nil
+	case "func":
+		if completer := app.lookupCompleter(arg); completer != nil {
+			return completer, nil
+		}
+		return nil, fmt.Errorf("completer %s not registered", arg)
+	}
+	return nil, fmt.Errorf("unknown completer %s", s)
+}
+
+// completer returns Completer of the flag by tag or by type
+func (fl *flag) completer() (Completer, error) {
+	if fl.tag.completer != "" {
+		return parseCompleter(fl.app, fl.tag.completer)
+	}
+	if fl.value.CanAddr() && fl.value.Addr().Type().Implements(completerType) {
+		return fl.value.Addr().Interface().(Completer), nil
+	}
+	if fl.value.Type().Implements(completerType) && fl.value.CanInterface() {
+		if fl.value.Kind() != reflect.Ptr || !fl.value.IsNil() {
+			return fl.value.Interface().(Completer), nil
+		}
+	}
+	return nil, nil
+}
+
+// fileCompleter completes paths of files and directories, files are
+// filtered by patterns if any
+type fileCompleter struct {
+	patterns []string
+	dirsOnly bool
+}
+
+func (c fileCompleter) Complete(ctx *Context, word string) []string {
+	dir, base := filepath.Split(word)
+	infos, err := ioutil.ReadDir(dirOrDot(dir))
+	if err != nil {
+		return nil
+	}
+	var candidates []string
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if info.IsDir() {
+			candidates = append(candidates, dir+name+string(os.PathSeparator))
+		} else if !c.dirsOnly && c.match(name) {
+			candidates = append(candidates, dir+name)
+		}
+	}
+	return candidates
+}
+
+func (c fileCompleter) match(name string) bool {
+	if len(c.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range c.patterns {
+		if ok, _ := filepath.Match(strings.TrimSpace(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func dirOrDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
@@ -0,0 +1,354 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/labstack/gommon/color"
+)
+
+// CompleteCommandName is the hidden sub-command called by completion
+// scripts, which writes candidates of the last word one per line:
+//
+//	app __complete deploy --e
+//
+// A candidate may be followed by a tab and it's description.
+const CompleteCommandName = "__complete"
+
+// CompletionShells lists shells supported by CompletionScript
+var CompletionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completion is a candidate of the word being completed
+type completion struct {
+	value string
+	desc  string
+}
+
+func (c completion) String() string {
+	if c.desc == "" {
+		return c.value
+	}
+	return c.value + "\t" + strings.Replace(c.desc, "\n", " ", -1)
+}
+
+// writeCompletions writes candidates of the last word in words
+func (cmd *Command) writeCompletions(opts *runOptions, words []string) error {
+	for _, c := range cmd.complete(opts.app, words) {
+		if _, err := fmt.Fprintln(opts.stdout, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// complete returns candidates of the last word in words, which are words
+// after program name. The router is resolved by SubRoute, and the flags
+// typed are parsed leniently by schema of argv.
+func (cmd *Command) complete(app *App, words []string) []completion {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	// some shells couldn't pass an empty argument
+	cur := words[len(words)-1]
+	if cur == `""` {
+		cur = ""
+	}
+	prev := words[:len(words)-1]
+
+	router := []string{}
+	for _, word := range prev {
+		if strings.HasPrefix(word, dashOne) {
+			break
+		}
+		router = append(router, word)
+	}
+	child, end := cmd.SubRoute(router)
+	rest := prev[end:]
+
+	clr := color.Color{}
+	clr.Disable()
+	cons := newConsole(strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	cons.noInput = true
+	ctx := &Context{
+		app:        app,
+		command:    child,
+		path:       child.Path(),
+		router:     router[:end],
+		argvList:   child.argvList(),
+		nativeArgs: rest,
+		writer:     ioutil.Discard,
+		console:    cons,
+		color:      clr,
+		flagSet:    newFlagSet(),
+	}
+	if !isEmptyArgvList(ctx.argvList) {
+		ctx.flagSet = parseArgvList(app, cons, rest, ctx.argvList, clr)
+	}
+	fs := ctx.flagSet
+
+	afterDashTwo := false
+	for _, word := range rest {
+		if word == dashTwo {
+			afterDashTwo = true
+		}
+	}
+	if !afterDashTwo {
+		// value of the previous flag
+		if n := len(rest); n > 0 {
+			if fl, ok := fs.flagMap[rest[n-1]]; ok && fl.needValue() {
+				return fl.completeValue(ctx, "", cur)
+			}
+		}
+		// value of `--flag=` form
+		if strings.HasPrefix(cur, dashOne) {
+			if i := strings.Index(cur, "="); i > 0 {
+				if fl, ok := fs.flagMap[cur[:i]]; ok {
+					return fl.completeValue(ctx, cur[:i+1], cur[i+1:])
+				}
+				return nil
+			}
+			return completeFlags(fs, cur)
+		}
+	}
+
+	var candidates []completion
+	if end == len(router) && len(rest) == 0 {
+		for _, c := range child.children {
+			for _, name := range append([]string{c.Name}, c.Aliases...) {
+				if strings.HasPrefix(name, cur) && !strings.HasPrefix(name, "_") {
+					candidates = append(candidates, completion{name, c.Desc})
+				}
+			}
+		}
+	}
+	if child.ArgCompleter != nil {
+		candidates = append(candidates, completeWith(ctx, child.ArgCompleter, "", cur)...)
+	}
+	return candidates
+}
+
+// completeWith returns candidates by completer which start with prefix,
+// every candidate is prepended by head
+func completeWith(ctx *Context, completer Completer, head, prefix string) []completion {
+	var candidates []completion
+	for _, s := range completer.Complete(ctx, prefix) {
+		if strings.HasPrefix(s, prefix) {
+			candidates = append(candidates, completion{value: head + s})
+		}
+	}
+	return candidates
+}
+
+// completeFlags returns names of flags with prefix, flags given already are
+// skipped unless they could be repeated
+func completeFlags(fs *flagSet, prefix string) []completion {
+	var candidates []completion
+	for _, fl := range fs.flagSlice {
+		if fl.isSet && !fl.isRepeatable() {
+			continue
+		}
+		for _, name := range fl.tag.names() {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, completion{name, fl.tag.usage})
+			}
+		}
+	}
+	return candidates
+}
+
+// needValue reports whether the flag takes the next argument as it's value
+func (fl *flag) needValue() bool {
+	return !fl.isBoolean() && !fl.isCounter()
+}
+
+// isRepeatable reports whether the flag could be given more than once
+func (fl *flag) isRepeatable() bool {
+	kind := fl.field.Type.Kind()
+	return kind == reflect.Slice || kind == reflect.Map || fl.isCounter()
+}
+
+// completeValue returns candidates of value of the flag with prefix by
+// choices or completer, every candidate is prepended by head
+func (fl *flag) completeValue(ctx *Context, head, prefix string) []completion {
+	if len(fl.tag.choices) > 0 {
+		var candidates []completion
+		for _, choice := range fl.tag.choices {
+			if strings.HasPrefix(choice, prefix) {
+				candidates = append(candidates, completion{value: head + choice})
+			}
+		}
+		return candidates
+	}
+	completer, err := fl.completer()
+	if err != nil {
+		// a misspelled or unregistered completer shouldn't be silent
+		fmt.Fprintf(ctx.app.stderr(), "%s: %v\n", fl.name(), err)
+		return nil
+	}
+	if completer == nil {
+		return nil
+	}
+	return completeWith(ctx, completer, head, prefix)
+}
+
+var nonWordRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// CompletionScript returns completion script of program name for shell,
+// which is one of CompletionShells. The script calls the program with
+// CompleteCommandName to get candidates.
+func CompletionScript(name, shell string) (string, error) {
+	text, ok := completionScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(CompletionShells, ", "))
+	}
+	var buf bytes.Buffer
+	err := template.Must(template.New(shell).Parse(text)).Execute(&buf, struct {
+		Name     string
+		Function string
+		Complete string
+	}{
+		Name:     name,
+		Function: nonWordRegexp.ReplaceAllString(name, "_"),
+		Complete: CompleteCommandName,
+	})
+	return buf.String(), err
+}
+
+// WriteCompletionScript writes completion script of program name for shell
+func WriteCompletionScript(w io.Writer, name, shell string) error {
+	script, err := CompletionScript(name, shell)
+	if err == nil {
+		_, err = io.WriteString(w, script)
+	}
+	return err
+}
+
+// CompletionCommand returns a builtin completion command, which prints
+// completion script of root command for the shell given as argument
+func CompletionCommand(desc string) *Command {
+	return &Command{
+		Name:   "completion",
+		Desc:   desc,
+		Text:   "Shells: " + strings.Join(CompletionShells, ", "),
+		NoHook: true,
+		Fn: func(ctx *Context) error {
+			args := ctx.NativeArgs()
+			if len(args) != 1 {
+				ctx.WriteUsage()
+				return errUsageShown
+			}
+			return WriteCompletionScript(ctx, ctx.Command().Root().Name, args[0])
+		},
+	}
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{.Name}}
+#
+# Load it in current shell:
+#   source <({{.Name}} completion bash)
+
+_{{.Function}}_completion() {
+    local IFS=$'\n' i c cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+    local -a words=()
+    # join --flag=value which is split by COMP_WORDBREAKS
+    for ((i = 1; i <= COMP_CWORD; i++)); do
+        if (( ${#words[@]} > 0 )) && [[ ${COMP_WORDS[i]} == "=" || ${COMP_WORDS[i-1]} == "=" ]]; then
+            words[${#words[@]}-1]+=${COMP_WORDS[i]}
+        else
+            words+=("${COMP_WORDS[i]}")
+        fi
+    done
+    COMPREPLY=()
+    for c in $({{.Name}} {{.Complete}} "${words[@]}" 2>/dev/null); do
+        c=${c%%$'\t'*}
+        if [[ $cur == "=" ]]; then
+            c="=${c#*=}"
+        elif [[ $prev == "=" ]]; then
+            c=${c#*=}
+        fi
+        COMPREPLY+=("$c")
+    done
+}
+
+complete -o default -F _{{.Function}}_completion {{.Name}}
+`,
+
+	"zsh": `#compdef {{.Name}}
+# zsh completion for {{.Name}}
+#
+# Load it in current shell:
+#   source <({{.Name}} completion zsh)
+
+_{{.Function}}_completion() {
+    local line
+    local -a candidates
+    for line in "${(@f)$({{.Name}} {{.Complete}} "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -z $line ]] && continue
+        if [[ $line == *$'\t'* ]]; then
+            candidates+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+        else
+            candidates+=("${line//:/\\:}")
+        fi
+    done
+    if (( ${#candidates} )); then
+        _describe '{{.Name}}' candidates
+    else
+        _files
+    fi
+}
+
+if [[ ${funcstack[1]} == "_{{.Name}}" ]]; then
+    _{{.Function}}_completion "$@"
+else
+    compdef _{{.Function}}_completion {{.Name}}
+fi
+`,
+
+	"fish": `# fish completion for {{.Name}}
+#
+# Load it in current shell:
+#   {{.Name}} completion fish | source
+
+function __{{.Function}}_completion
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l candidates ({{.Name}} {{.Complete}} $tokens (commandline -ct) 2>/dev/null)
+    if test (count $candidates) -eq 0
+        __fish_complete_path (commandline -ct)
+    else
+        printf '%s\n' $candidates
+    end
+end
+
+complete -c {{.Name}} -f -a '(__{{.Function}}_completion)'
+`,
+
+	"powershell": `# powershell completion for {{.Name}}
+#
+# Load it in current shell:
+#   {{.Name}} completion powershell | Out-String | Invoke-Expression
+
+Register-ArgumentCompleter -Native -CommandName '{{.Name}}' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        Select-Object -Skip 1 |
+        ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') {
+        $words += '""'
+    }
+    & '{{.Name}}' {{.Complete}} @words 2>$null | ForEach-Object {
+        $value, $desc = $_ -split "` + "`" + `t", 2
+        if (-not $desc) { $desc = $value }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $desc)
+    }
+}
+`,
+}
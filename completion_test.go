@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompletionTree() *Command {
+	type rootT struct {
+		Helper
+		Verbose Counter `cli:"v" usage:"verbose level"`
+	}
+	type deployT struct {
+		Env    string   `cli:"e,env" usage:"environment" choices:"dev,staging,prod"`
+		Tags   []string `cli:"tag" usage:"tags" choices:"a,b"`
+		DryRun bool     `cli:"dry-run" usage:"print only"`
+		Secret string   `cli:"-"`
+	}
+	root := &Command{
+		Name:   "app",
+		Global: true,
+		Argv:   func() interface{} { return new(rootT) },
+		Fn:     donothing,
+	}
+	root.Register(&Command{
+		Name:    "deploy",
+		Aliases: []string{"dp"},
+		Desc:    "deploy app",
+		Argv:    func() interface{} { return new(deployT) },
+		Fn:      donothing,
+	})
+	root.Register(&Command{Name: "delete", Desc: "delete app", Fn: donothing})
+	root.Register(&Command{Name: "_hidden", Fn: donothing})
+	root.Register(CompletionCommand("print completion script"))
+	return root
+}
+
+func TestComplete(t *testing.T) {
+	root := newCompletionTree()
+	for _, tt := range []struct {
+		words []string
+		want  []string
+	}{
+		{nil, []string{"deploy\tdeploy app", "dp\tdeploy app", "delete\tdelete app", "completion\tprint completion script"}},
+		{[]string{"de"}, []string{"deploy\tdeploy app", "delete\tdelete app"}},
+		{[]string{`""`}, []string{"deploy\tdeploy app", "dp\tdeploy app", "delete\tdelete app", "completion\tprint completion script"}},
+		{[]string{"-"}, []string{"-h\tdisplay help information", "--help\tdisplay help information", "-v\tverbose level"}},
+		{[]string{"dp", "--"}, []string{"--env\tenvironment", "--tag\ttags", "--dry-run\tprint only", "--help\tdisplay help information"}},
+		{[]string{"deploy", "--env", "dev", "--"}, []string{"--tag\ttags", "--dry-run\tprint only", "--help\tdisplay help information"}},
+		{[]string{"deploy", "--tag", "a", "--t"}, []string{"--tag\ttags"}},
+		{[]string{"deploy", "-e", ""}, []string{"dev", "staging", "prod"}},
+		{[]string{"deploy", "--env", "s"}, []string{"staging"}},
+		{[]string{"deploy", "--env=p"}, []string{"--env=prod"}},
+		{[]string{"deploy", "--dry-run", ""}, nil},
+		{[]string{"deploy", "--", "-"}, nil},
+		{[]string{"deploy", "arg", ""}, nil},
+	} {
+		var got []string
+		for _, c := range root.complete(defaultApp, tt.words) {
+			got = append(got, c.String())
+		}
+		assert.Equal(t, tt.want, got, "%q", tt.words)
+	}
+
+	// the hidden command
+	stdout := new(bytes.Buffer)
+	require.Nil(t, root.RunWith([]string{CompleteCommandName, "deploy", "--env", ""}, stdout, nil))
+	assert.Equal(t, "dev\nstaging\nprod\n", stdout.String())
+}
+
+func TestCompletionScript(t *testing.T) {
+	root := &Command{Name: "app"}
+	root.Register(CompletionCommand("print completion script"))
+	for _, shell := range CompletionShells {
+		stdout := new(bytes.Buffer)
+		require.Nil(t, root.RunWith([]string{"completion", shell}, stdout, nil))
+		script := stdout.String()
+		assert.True(t, strings.HasPrefix(script, "# "+shell+" completion for app\n") ||
+			strings.HasPrefix(script, "#compdef app\n"), script)
+		assert.Contains(t, script, " "+CompleteCommandName+" ")
+	}
+	_, err := CompletionScript("my-app", "tcsh")
+	assert.EqualError(t, err, `unsupported shell "tcsh", expected one of bash, zsh, fish, powershell`)
+
+	script, err := CompletionScript("my-app", "bash")
+	require.Nil(t, err)
+	assert.Contains(t, script, "complete -o default -F _my_app_completion my-app\n")
+}
+
+type testRegion string
+
+func (r *testRegion) Decode(s string) error {
+	*r = testRegion(s)
+	return nil
+}
+
+func (r *testRegion) Complete(ctx *Context, word string) []string {
+	return []string{"us-east", "us-west", "eu-central"}
+}
+
+func TestCompleter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-completer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.yaml", "b.json", ".hidden.yaml", "conf/"} {
+		if strings.HasSuffix(name, "/") {
+			require.Nil(t, os.Mkdir(filepath.Join(dir, name), 0755))
+		} else {
+			require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+		}
+	}
+
+	type argT struct {
+		Env     string     `cli:"env" choices:"dev,prod"`
+		Cluster string     `cli:"cluster" complete:"func:clusters"`
+		Region  testRegion `cli:"region"`
+		Config  string     `cli:"config" complete:"files:*.yaml,*.yml"`
+		Dir     string     `cli:"dir" complete:"dirs"`
+		Unknown string     `cli:"unknown" complete:"func:unknown"`
+	}
+	app := NewApp(&Command{
+		Name: "app",
+		Argv: func() interface{} { return new(argT) },
+		ArgCompleter: CompleterFunc(func(ctx *Context, word string) []string {
+			return []string{fmt.Sprintf("arg%d", len(ctx.Args())), "other"}
+		}),
+		Fn: donothing,
+	})
+	app.RegisterCompleter("clusters", CompleterFunc(func(ctx *Context, word string) []string {
+		return []string{ctx.Argv().(*argT).Env + "-1", ctx.Argv().(*argT).Env + "-2"}
+	}))
+	assert.Panics(t, func() { app.RegisterCompleter("clusters", CompleterFunc(nil)) })
+	stderr := new(bytes.Buffer)
+	app.Stderr = stderr
+
+	sep := string(os.PathSeparator)
+	for _, tt := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"--env", "prod", "--cluster", ""}, []string{"prod-1", "prod-2"}},
+		{[]string{"--cluster=d"}, nil},
+		{[]string{"--env", "dev", "--cluster=d"}, []string{"--cluster=dev-1", "--cluster=dev-2"}},
+		{[]string{"--region", "us"}, []string{"us-east", "us-west"}},
+		{[]string{"--config", dir + sep}, []string{dir + sep + "a.yaml", dir + sep + "conf" + sep}},
+		{[]string{"--config", dir + sep + "."}, []string{dir + sep + ".hidden.yaml"}},
+		{[]string{"--dir", dir + sep}, []string{dir + sep + "conf" + sep}},
+		{[]string{"--unknown", ""}, nil},
+		{[]string{""}, []string{"arg0", "other"}},
+		{[]string{"x", "y", "a"}, []string{"arg2"}},
+	} {
+		var got []string
+		for _, c := range app.Root.complete(app, tt.words) {
+			got = append(got, c.String())
+		}
+		assert.Equal(t, tt.want, got, "%q", tt.words)
+	}
+	// unregistered completer is reported
+	assert.Equal(t, "--unknown: completer unknown not registered\n", stderr.String())
+}
@@ -2,15 +2,16 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 
 	"github.com/labstack/gommon/color"
-	"github.com/mattn/go-colorable"
 )
 
 type (
@@ -23,7 +24,22 @@ type (
 		flagSet    *flagSet
 		command    *Command
 		writer     io.Writer
+		console    *console
 		color      color.Color
+		app        *App
+		cancel     context.CancelFunc
+		width      int // width of terminal, 0 if unknown
+
+		mu        sync.Mutex // protect following data
+		context   context.Context
+		values    map[interface{}]interface{}
+		resources []resource
+		pending   map[interface{}]*creation // values being created by providers
+
+		// owner is the context sharing data above with this one, which is
+		// passed to providers, creating are keys being created by them
+		owner    *Context
+		creating []interface{}
 
 		HTTPRequest  *http.Request
 		HTTPResponse http.ResponseWriter
@@ -40,17 +56,24 @@ type (
 	}
 )
 
-func newContext(path string, router, args []string, argvList []interface{}, clr color.Color) (*Context, error) {
+func newContext(opts *runOptions, path string, router, args []string, argvList []interface{}, clr color.Color) (*Context, error) {
 	ctx := &Context{
+		app:        opts.app,
+		context:    opts.ctx,
+		writer:     opts.stdout,
+		console:    opts.app.newConsole(opts.stdin, opts.stdout, opts.stderr, opts.noInput),
 		path:       path,
 		router:     router,
 		argvList:   argvList,
 		nativeArgs: args,
 		color:      clr,
+		width:      opts.width,
 		flagSet:    newFlagSet(),
 	}
+	ctx.console.wizard = opts.interactive
+	ctx.console.ctx = opts.ctx
 	if !isEmptyArgvList(argvList) {
-		ctx.flagSet = parseArgvList(args, argvList, ctx.color)
+		ctx.flagSet = parseArgvList(ctx.app, ctx.console, args, argvList, ctx.color)
 		if ctx.flagSet.err != nil {
 			return ctx, ctx.flagSet.err
 		}
@@ -58,6 +81,60 @@ func newContext(path string, router, args []string, argvList []interface{}, clr
 	return ctx, nil
 }
 
+// Context returns context.Context of the invocation. It's derived from
+// HTTPRequest.Context() in server mode, cancelled by the first SIGINT or
+// SIGTERM in App.Run and Run unless App.NoSignalHandler(the second one
+// terminates the process), or after Timeout of command.
+func (ctx *Context) Context() context.Context {
+	o := ctx.shared()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.getContext()
+}
+
+func (ctx *Context) getContext() context.Context {
+	if ctx.context == nil {
+		return context.Background()
+	}
+	return ctx.context
+}
+
+// Set stores value of key, which could be read by Value and by Value of
+// Context(), so that hooks could pass data to handlers
+func (ctx *Context) Set(key, value interface{}) {
+	o := ctx.shared()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.set(key, value)
+}
+
+func (ctx *Context) set(key, value interface{}) {
+	if ctx.values == nil {
+		ctx.values = make(map[interface{}]interface{})
+	}
+	ctx.values[key] = value
+	ctx.context = context.WithValue(ctx.getContext(), key, value)
+}
+
+// Value returns value of key stored by Set or carried by Context()
+func (ctx *Context) Value(key interface{}) interface{} {
+	o := ctx.shared()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if value, ok := o.values[key]; ok {
+		return value
+	}
+	return o.getContext().Value(key)
+}
+
+// shared returns the context owning values, context and resources
+func (ctx *Context) shared() *Context {
+	if ctx.owner != nil {
+		return ctx.owner
+	}
+	return ctx
+}
+
 // Path returns full command name
 // `./app hello world -a --xyz=1` will returns "hello world"
 func (ctx *Context) Path() string {
@@ -197,6 +274,11 @@ func (ctx *Context) Command() *Command {
 	return ctx.command
 }
 
+// App returns the app which runs current command
+func (ctx *Context) App() *App {
+	return appOrDefault(ctx.app)
+}
+
 // Usage returns current command's usage with current context
 func (ctx *Context) Usage() string {
 	return ctx.command.Usage(ctx)
@@ -210,11 +292,64 @@ func (ctx *Context) WriteUsage() {
 // Writer returns writer
 func (ctx *Context) Writer() io.Writer {
 	if ctx.writer == nil {
-		ctx.writer = colorable.NewColorableStdout()
+		ctx.writer = ctx.App().stdout()
 	}
 	return ctx.writer
 }
 
+// Stdin returns reader of standard input
+func (ctx *Context) Stdin() io.Reader {
+	if ctx.console == nil {
+		return ctx.App().stdin()
+	}
+	return ctx.console.input()
+}
+
+// Stderr returns writer of standard error
+func (ctx *Context) Stderr() io.Writer {
+	if ctx.console == nil {
+		return ctx.App().stderr()
+	}
+	return ctx.console.stderr
+}
+
+// getConsole returns console of the invocation
+func (ctx *Context) getConsole() *console {
+	if ctx.console == nil {
+		ctx.console = ctx.App().console()
+	}
+	return ctx.console
+}
+
+// Confirm asks a yes/no question, dft is used if the answer is empty.
+// Prompts are written to Stderr and answered from Stdin, and
+// ErrInputUnavailable returned if interactive input is unavailable.
+func (ctx *Context) Confirm(question string, dft bool) (bool, error) {
+	return ctx.getConsole().confirm(question, dft)
+}
+
+// Select asks to choose one of options by number or by the option itself
+func (ctx *Context) Select(question string, options []string) (string, error) {
+	return ctx.getConsole().selectOne(question, options, "")
+}
+
+// MultiSelect asks to choose any of options, separated by commas
+func (ctx *Context) MultiSelect(question string, options []string) ([]string, error) {
+	return ctx.getConsole().selectMany(question, options)
+}
+
+// Input asks question until the answer is accepted by validate,
+// any answer is accepted if validate is nil
+func (ctx *Context) Input(question string, validate func(string) error) (string, error) {
+	return ctx.getConsole().inputValid(question, validate)
+}
+
+// Password reads a password without echo, and asks for it again until
+// both entries are the same if confirm is true
+func (ctx *Context) Password(question string, confirm bool) (string, error) {
+	return ctx.getConsole().passwordTwice(question, confirm)
+}
+
 // Write implements io.Writer
 func (ctx *Context) Write(data []byte) (n int, err error) {
 	return ctx.Writer().Write(data)
@@ -2,9 +2,19 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestContextGetArgvList(t *testing.T) {
@@ -149,3 +159,346 @@ func TestContextWriter(t *testing.T) {
 }
 end`)
 }
+
+func TestContextStreams(t *testing.T) {
+	type argT struct {
+		Name     string `cli:"name" prompt:"your name"`
+		Password string `pw:"p" prompt:"password"`
+		Yes      bool   `cli:"y" prompt:"sure"`
+	}
+	var (
+		stdin  = bytes.NewBufferString("mkideal\nsecret\ny\nfrom stdin")
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+	)
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			argv := ctx.Argv().(*argT)
+			assert.Equal(t, "mkideal", argv.Name)
+			assert.Equal(t, "secret", argv.Password)
+			assert.True(t, argv.Yes)
+			data, err := ioutil.ReadAll(ctx.Stdin())
+			assert.Nil(t, err)
+			ctx.String("%s", data)
+			fmt.Fprint(ctx.Stderr(), "done")
+			return nil
+		},
+	}
+	assert.Nil(t, root.RunWithIO(nil, stdin, stdout, stderr))
+	assert.Equal(t, "from stdin", stdout.String())
+	assert.Equal(t, "your name: password: \nsure [y/N]: done", stderr.String())
+}
+
+func TestContextNoInput(t *testing.T) {
+	type argT struct {
+		Name string `cli:"*name" prompt:"your name"`
+		Note string `cli:"note" prompt:"note" dft:"none"`
+	}
+	newApp := func() *App {
+		return NewApp(&Command{
+			Name: "root",
+			Argv: func() interface{} { return new(argT) },
+			Fn: func(ctx *Context) error {
+				argv := ctx.Argv().(*argT)
+				ctx.String("%s,%s", argv.Name, argv.Note)
+				return nil
+			},
+		})
+	}
+
+	// a pipe isn't read by default
+	r, w, err := os.Pipe()
+	require.Nil(t, err)
+	defer r.Close()
+	fmt.Fprint(w, "mkideal\n")
+	w.Close()
+	app := newApp()
+	app.Stdin, app.Stdout, app.Stderr = r, new(bytes.Buffer), new(bytes.Buffer)
+	err = app.Execute(nil)
+	require.Error(t, err)
+	assert.Equal(t, "ERR! required parameter --name missing (interactive input unavailable)", err.Error())
+
+	// read lines from pipe if allowed
+	app.AllowPipedInput = true
+	assert.Nil(t, app.Execute(nil))
+	assert.Equal(t, "mkideal,none", app.Stdout.(*bytes.Buffer).String())
+
+	// --no-input and NoInputEnv disable injected readers, too
+	for _, tt := range []struct {
+		args []string
+		env  string
+	}{
+		{[]string{"--no-input"}, ""},
+		{nil, "1"},
+	} {
+		stdout := new(bytes.Buffer)
+		app := newApp()
+		app.Stdin, app.Stdout, app.Stderr = strings.NewReader("mkideal\n"), stdout, new(bytes.Buffer)
+		app.Getenv = func(string) string { return tt.env }
+		assert.Nil(t, app.Execute(append(tt.args, "--name=x")))
+		assert.Equal(t, "x,none", stdout.String())
+		assert.Equal(t, 8, app.Stdin.(*strings.Reader).Len())
+	}
+}
+
+func TestContextPrompts(t *testing.T) {
+	stdin := strings.NewReader(strings.Join([]string{
+		"maybe", "y", // Confirm
+		"4", "prod", // Select
+		"1, 3 1",  // MultiSelect
+		"x", "42", // Input
+		"a", "b", "c", "c", // Password
+	}, "\n"))
+	stderr := new(bytes.Buffer)
+	root := &Command{
+		Name: "root",
+		Fn: func(ctx *Context) error {
+			yes, err := ctx.Confirm("sure", false)
+			require.Nil(t, err)
+			assert.True(t, yes)
+
+			options := []string{"dev", "staging", "prod"}
+			env, err := ctx.Select("env", options)
+			require.Nil(t, err)
+			assert.Equal(t, "prod", env)
+
+			envs, err := ctx.MultiSelect("envs", options)
+			require.Nil(t, err)
+			assert.Equal(t, []string{"dev", "prod"}, envs)
+
+			n, err := ctx.Input("number", func(s string) error {
+				_, err := strconv.Atoi(s)
+				return err
+			})
+			require.Nil(t, err)
+			assert.Equal(t, "42", n)
+
+			pw, err := ctx.Password("password", true)
+			require.Nil(t, err)
+			assert.Equal(t, "c", pw)
+
+			_, err = ctx.Confirm("again", true)
+			assert.Equal(t, io.EOF, err)
+			return nil
+		},
+	}
+	assert.Nil(t, root.RunWithIO(nil, stdin, new(bytes.Buffer), stderr))
+	assert.Equal(t, `sure [y/N]: please answer yes or no
+sure [y/N]: env:
+  1) dev
+  2) staging
+  3) prod
+Enter a number [1-3]: please enter a number or an option listed
+Enter a number [1-3]: envs:
+  1) dev
+  2) staging
+  3) prod
+Enter numbers separated by commas [1-3]: number: strconv.Atoi: parsing "x": invalid syntax
+number: password: 
+password (again): 
+passwords do not match
+password: 
+password (again): 
+again [Y/n]: `, stderr.String())
+
+	// Confirm fails without interactive input
+	root.Fn = func(ctx *Context) error {
+		_, err := ctx.Confirm("sure", true)
+		return err
+	}
+	assert.Equal(t, ErrInputUnavailable, root.RunWithIO([]string{"--no-input"}, stdin, nil, nil))
+}
+
+func TestChoices(t *testing.T) {
+	type argT struct {
+		Env  string   `cli:"env" choices:"dev, staging, prod" prompt:"env"`
+		Tags []string `cli:"tag" choices:"a,b,c" prompt:"tags"`
+	}
+	var argv *argT
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			argv = ctx.Argv().(*argT)
+			return nil
+		},
+	}
+	run := func(stdin string, args ...string) error {
+		return root.RunWithIO(args, strings.NewReader(stdin), new(bytes.Buffer), new(bytes.Buffer))
+	}
+	assert.Nil(t, run("2\nc a\n"))
+	assert.Equal(t, &argT{Env: "staging", Tags: []string{"c", "a"}}, argv)
+
+	assert.Nil(t, run("", "--env=prod", "--tag=b"))
+	assert.Equal(t, &argT{Env: "prod", Tags: []string{"b"}}, argv)
+
+	err := run("", "--env=test", "--tag=b")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "`test' isn't one of dev, staging, prod")
+
+	// default value is one of choices, too
+	type dftT struct {
+		Env string `cli:"env" choices:"dev,prod" dft:"staging"`
+	}
+	bad := &Command{
+		Name: "bad",
+		Argv: func() interface{} { return new(dftT) },
+		Fn:   func(*Context) error { panic("unreachable") },
+	}
+	err = bad.RunWithIO(nil, strings.NewReader(""), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "default value of --env: `staging' isn't one of dev, prod")
+}
+
+func TestInteractive(t *testing.T) {
+	type argT struct {
+		Helper
+		Name    string   `cli:"name" usage:"your name"`
+		Env     string   `cli:"*env" usage:"environment" choices:"dev,prod"`
+		Port    int      `cli:"port" usage:"port to listen" dft:"8080"`
+		Debug   bool     `cli:"debug"`
+		Tags    []string `cli:"tag" usage:"tags"`
+		Secret  string   `pw:"secret" usage:"secret"`
+		Verbose int      `cli:"v" usage:"verbose level"`
+	}
+	var argv *argT
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			argv = ctx.Argv().(*argT)
+			return nil
+		},
+	}
+	stdin := strings.NewReader(strings.Join([]string{
+		"2",     // --env
+		"x", "", // --port
+		"y",        // --debug
+		"a, b",     // --tag
+		"s",        // --secret
+		"abc", "3", // -v
+	}, "\n"))
+	stderr := new(bytes.Buffer)
+	assert.Nil(t, root.RunWithIO([]string{"--interactive", "--name=mk"}, stdin, new(bytes.Buffer), stderr))
+	assert.Equal(t, &argT{
+		Name:    "mk",
+		Env:     "prod",
+		Port:    8080,
+		Debug:   true,
+		Tags:    []string{"a", "b"},
+		Secret:  "s",
+		Verbose: 3,
+	}, argv)
+	assert.Equal(t, "environment (--env):\n  1) dev\n  2) prod\nEnter a number [1-2]: "+
+		"port to listen (--port) [8080]: `x' couldn't converted to an int\n"+
+		"port to listen (--port) [8080]: --debug [y/N]: tags (--tag): secret (--secret): \n"+
+		"verbose level (-v): `abc' couldn't converted to an int\nverbose level (-v): ", stderr.String())
+
+	// required flags are still reported without input
+	err := root.RunWithIO([]string{"--interactive", "--no-input"}, nil, new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required parameter --env missing (interactive input unavailable)")
+}
+
+func TestExtractSwitch(t *testing.T) {
+	type argT struct {
+		Msg         string `cli:"m,msg"`
+		Interactive bool   `cli:"interactive"`
+	}
+	type subT struct {
+		Msg string `cli:"m,msg"`
+	}
+	var (
+		argv *argT
+		sub  *subT
+		args []string
+	)
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			argv = ctx.Argv().(*argT)
+			args = ctx.Args()
+			return nil
+		},
+	}
+	root.Register(&Command{
+		Name: "sub",
+		Argv: func() interface{} { return new(subT) },
+		Fn: func(ctx *Context) error {
+			sub = ctx.Argv().(*subT)
+			args = ctx.Args()
+			return nil
+		},
+	})
+	for _, tt := range []struct {
+		in, rest []string
+		found    bool
+	}{
+		{[]string{"sub", "--no-input", "-m", "x"}, []string{"sub", "-m", "x"}, true},
+		{[]string{"sub", "-m", "--no-input"}, []string{"sub", "-m", "--no-input"}, false},
+		{[]string{"sub", "--", "--no-input"}, []string{"sub", "--", "--no-input"}, false},
+		{[]string{"sub", "-m=x", "--no-input"}, []string{"sub", "-m=x"}, true},
+	} {
+		rest, found := root.extractSwitch(tt.in, NoInputFlag)
+		assert.Equal(t, tt.rest, rest, "%v", tt.in)
+		assert.Equal(t, tt.found, found, "%v", tt.in)
+	}
+
+	// arguments after `--`
+	assert.Nil(t, root.RunWithIO([]string{"sub", "-m", "x", "--", "--no-input", "--help-json"}, nil, new(bytes.Buffer), nil))
+	assert.Equal(t, "x", sub.Msg)
+	assert.Equal(t, []string{"--no-input", "--help-json"}, args)
+
+	// flags declared by the command itself
+	assert.Nil(t, root.RunWithIO([]string{"--interactive", "-m", "x"}, nil, new(bytes.Buffer), nil))
+	assert.True(t, argv.Interactive)
+	assert.Equal(t, "x", argv.Msg)
+}
+
+func TestPromptTimeout(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name" prompt:"name" prompt-timeout:"20ms"`
+	}
+	stderr := new(bytes.Buffer)
+	r, w := io.Pipe()
+	defer w.Close()
+	app := NewApp(&Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			// the read timed out is taken by next prompt
+			_, err := ctx.Input("first", nil)
+			assert.Equal(t, ErrPromptTimeout, err)
+			go fmt.Fprintln(w, "answer")
+			line, err := ctx.Input("second", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "answer", line)
+			return nil
+		},
+	})
+	app.Stdin, app.Stdout, app.Stderr = r, new(bytes.Buffer), stderr
+	err := app.Execute(nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPromptTimeout), err.Error())
+	assert.Equal(t, "name: \n", stderr.String())
+
+	// the pending read of last run isn't shared
+	stderr.Reset()
+	r, w = io.Pipe()
+	defer w.Close()
+	app.Stdin = r
+	app.PromptTimeout = 50 * time.Millisecond
+	assert.Nil(t, app.Execute([]string{"--name=x"}))
+	assert.Equal(t, "first: \nsecond: ", stderr.String())
+
+	// cancelled prompts
+	c := newConsole(r, new(bytes.Buffer), new(bytes.Buffer))
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx = ctx
+	cancel()
+	_, err = c.prompt("name: ", false)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,442 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ManHeader is the header of generated man pages
+type ManHeader struct {
+	Section string    // section of manual, "1" used if empty
+	Date    time.Time // date of last change, omitted if zero
+	Source  string    // source of the program, e.g. "app 1.0.0"
+	Manual  string    // title of the manual
+}
+
+func (h *ManHeader) section() string {
+	if h == nil || h.Section == "" {
+		return "1"
+	}
+	return h.Section
+}
+
+// GenMan writes man page of cmd in roff format
+func GenMan(cmd *Command, w io.Writer, header *ManHeader) error {
+	var (
+		buf     bytes.Buffer
+		section = header.section()
+		date    string
+		source  string
+		manual  string
+	)
+	if header != nil {
+		if !header.Date.IsZero() {
+			date = header.Date.Format("January 2006")
+		}
+		source, manual = header.Source, header.Manual
+	}
+	fmt.Fprintf(&buf, ".TH %s %s %s %s %s\n", roffQuote(strings.ToUpper(cmd.docName())), roffQuote(section), roffQuote(date), roffQuote(source), roffQuote(manual))
+	fmt.Fprintf(&buf, ".SH NAME\n%s", roffEscape(cmd.docName()))
+	if cmd.Desc != "" {
+		fmt.Fprintf(&buf, ` \- %s`, roffEscape(cmd.Desc))
+	}
+	fmt.Fprintf(&buf, "\n.SH SYNOPSIS\n%s\n", roffEscape(cmd.synopsis(cmd.fullPath())))
+	if text := cmd.docText(); text != "" {
+		fmt.Fprintf(&buf, ".SH DESCRIPTION\n%s\n", roffParagraphs(text))
+	}
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(&buf, ".SH ALIASES\n%s\n", roffEscape(strings.Join(cmd.Aliases, ", ")))
+	}
+
+	fs, _ := usageFlags(cmd.argvList())
+	if len(fs) > 0 {
+		buf.WriteString(".SH OPTIONS\n")
+		for _, fl := range fs {
+			names := make([]string, 0, len(fl.tag.names()))
+			for _, name := range fl.tag.names() {
+				names = append(names, `\fB`+roffEscape(name)+`\fR`)
+			}
+			fmt.Fprintf(&buf, ".TP\n%s", strings.Join(names, ", "))
+			if valueName := fl.docValueName(); valueName != "" {
+				fmt.Fprintf(&buf, `=\fI%s\fR`, roffEscape(valueName))
+			}
+			buf.WriteByte('\n')
+			if fl.tag.usage != "" {
+				fmt.Fprintf(&buf, "%s\n", roffParagraphs(fl.tag.usage))
+			}
+			if notes := fl.docNotes(func(s string) string { return s }); len(notes) > 0 {
+				if fl.tag.usage != "" {
+					buf.WriteString(".br\n")
+				}
+				fmt.Fprintf(&buf, "%s.\n", roffEscape(strings.Join(notes, ". ")))
+			}
+		}
+	}
+
+	children := cmd.docChildren()
+	if len(children) > 0 {
+		buf.WriteString(".SH COMMANDS\n")
+		for _, child := range children {
+			fmt.Fprintf(&buf, ".TP\n\\fB%s\\fR\n%s\n", roffEscape(child.Name), roffEscape(child.Desc))
+		}
+	}
+
+	if len(cmd.Examples) > 0 {
+		buf.WriteString(".SH EXAMPLES\n")
+		for _, example := range cmd.Examples {
+			if example.Desc != "" {
+				fmt.Fprintf(&buf, ".PP\n%s\n", roffEscape(example.Desc))
+			}
+			fmt.Fprintf(&buf, ".PP\n.RS 4\n.nf\n%s\n.fi\n.RE\n", roffEscape(example.Cmd))
+		}
+	}
+
+	if envs := cmd.docEnvs(fs); len(envs) > 0 {
+		buf.WriteString(".SH ENVIRONMENT\n")
+		for _, env := range envs {
+			fmt.Fprintf(&buf, ".TP\n\\fB%s\\fR\n%s\n", roffEscape(env.name), roffEscape(env.desc))
+		}
+	}
+
+	var seeAlso []string
+	if cmd.parent != nil {
+		seeAlso = append(seeAlso, cmd.parent.docName())
+	}
+	for _, child := range children {
+		seeAlso = append(seeAlso, child.docName())
+	}
+	if len(seeAlso) > 0 {
+		buf.WriteString(".SH SEE ALSO\n")
+		for i, name := range seeAlso {
+			sep := ","
+			if i == len(seeAlso)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, ".BR %s (%s)%s\n", roffEscape(name), section, sep)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// GenManTree writes man pages of root and all it's descendants into dir,
+// the file of a command is named after it's path joined by "-", e.g.
+// app-deploy.1
+func GenManTree(root *Command, dir string, header *ManHeader) error {
+	return genDocTree(root, dir, "."+header.section(), func(cmd *Command, w io.Writer) error {
+		return GenMan(cmd, w, header)
+	})
+}
+
+// GenMarkdown writes documentation of cmd in Markdown, which links to
+// documents of parent and children generated by GenMarkdownTree
+func GenMarkdown(cmd *Command, w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", cmd.fullPath())
+	if cmd.Desc != "" {
+		fmt.Fprintf(&buf, "%s\n\n", cmd.Desc)
+	}
+	fmt.Fprintf(&buf, "## Synopsis\n\n```\n%s\n```\n\n", cmd.synopsis(cmd.fullPath()))
+	if text := cmd.docText(); text != "" {
+		fmt.Fprintf(&buf, "%s\n\n", text)
+	}
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(&buf, "Aliases: `%s`\n\n", strings.Join(cmd.Aliases, "`, `"))
+	}
+
+	fs, _ := usageFlags(cmd.argvList())
+	if len(fs) > 0 {
+		buf.WriteString("## Options\n\n| Option | Description |\n| --- | --- |\n")
+		for _, fl := range fs {
+			option := strings.Join(fl.tag.names(), ", ")
+			if valueName := fl.docValueName(); valueName != "" {
+				option += "=" + valueName
+			}
+			desc := fl.tag.usage
+			if notes := fl.docNotes(func(s string) string { return "`" + s + "`" }); len(notes) > 0 {
+				if desc != "" {
+					desc += "<br>"
+				}
+				desc += strings.Join(notes, ". ") + "."
+			}
+			fmt.Fprintf(&buf, "| `%s` | %s |\n", option, markdownCell(desc))
+		}
+		buf.WriteByte('\n')
+	}
+
+	children := cmd.docChildren()
+	if len(children) > 0 {
+		buf.WriteString("## Commands\n\n| Command | Description |\n| --- | --- |\n")
+		for _, child := range children {
+			fmt.Fprintf(&buf, "| [%s](%s.md) | %s |\n", child.Name, child.docName(), markdownCell(child.Desc))
+		}
+		buf.WriteByte('\n')
+	}
+
+	if len(cmd.Examples) > 0 {
+		buf.WriteString("## Examples\n\n")
+		for _, example := range cmd.Examples {
+			if example.Desc != "" {
+				fmt.Fprintf(&buf, "%s\n\n", example.Desc)
+			}
+			fmt.Fprintf(&buf, "```\n%s\n```\n\n", example.Cmd)
+		}
+	}
+
+	if envs := cmd.docEnvs(fs); len(envs) > 0 {
+		buf.WriteString("## Environment\n\n")
+		for _, env := range envs {
+			fmt.Fprintf(&buf, "- `%s`: %s\n", env.name, env.desc)
+		}
+		buf.WriteByte('\n')
+	}
+
+	if cmd.parent != nil {
+		fmt.Fprintf(&buf, "## See also\n\n- [%s](%s.md)", cmd.parent.fullPath(), cmd.parent.docName())
+		if cmd.parent.Desc != "" {
+			fmt.Fprintf(&buf, ": %s", cmd.parent.Desc)
+		}
+		buf.WriteString("\n\n")
+	}
+	_, err := w.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	if err == nil {
+		_, err = io.WriteString(w, "\n")
+	}
+	return err
+}
+
+// GenMarkdownTree writes Markdown documents of root and all it's
+// descendants into dir, file of a command is named after it's path joined
+// by "-", e.g. app-deploy.md, and index.md lists all commands
+func GenMarkdownTree(root *Command, dir string) error {
+	if err := genDocTree(root, dir, ".md", GenMarkdown); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", root.fullPath())
+	if root.Desc != "" {
+		fmt.Fprintf(&buf, "%s\n\n", root.Desc)
+	}
+	buf.WriteString("## Commands\n\n")
+	var walk func(cmd *Command, depth int)
+	walk = func(cmd *Command, depth int) {
+		fmt.Fprintf(&buf, "%s- [%s](%s.md)", strings.Repeat("  ", depth), cmd.fullPath(), cmd.docName())
+		if cmd.Desc != "" {
+			fmt.Fprintf(&buf, ": %s", cmd.Desc)
+		}
+		buf.WriteByte('\n')
+		for _, child := range cmd.docChildren() {
+			walk(child, depth+1)
+		}
+	}
+	walk(root, 0)
+	return ioutil.WriteFile(filepath.Join(dir, "index.md"), buf.Bytes(), 0644)
+}
+
+// DocsCommand returns a builtin docs command, which writes man pages or
+// Markdown documents of root command into the directory given:
+//
+//	app docs man ./man
+//	app docs markdown ./docs
+func DocsCommand(desc string) *Command {
+	return &Command{
+		Name:   "docs",
+		Desc:   desc,
+		Text:   "Usage: docs <man|markdown> <directory>",
+		NoHook: true,
+		Fn: func(ctx *Context) error {
+			args := ctx.NativeArgs()
+			if len(args) != 2 {
+				ctx.WriteUsage()
+				return errUsageShown
+			}
+			root := ctx.Command().Root()
+			switch args[0] {
+			case "man":
+				return GenManTree(root, args[1], nil)
+			case "markdown", "md":
+				return GenMarkdownTree(root, args[1])
+			}
+			return fmt.Errorf("unsupported format %q, expected man or markdown", args[0])
+		},
+	}
+}
+
+func genDocTree(cmd *Command, dir, ext string, gen func(*Command, io.Writer) error) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gen(cmd, &buf); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, cmd.docName()+ext), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	for _, child := range cmd.docChildren() {
+		if err := genDocTree(child, dir, ext, gen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isHidden reports whether the command is hidden from completion and
+// documents, whose name starts with `_`
+func (cmd *Command) isHidden() bool {
+	return strings.HasPrefix(cmd.Name, "_")
+}
+
+// fullPath returns space-separated command full name with root's name
+func (cmd *Command) fullPath() string {
+	return strings.TrimSpace(cmd.Root().Name + " " + cmd.Path())
+}
+
+// docName returns name of the command's document
+func (cmd *Command) docName() string {
+	return strings.Replace(cmd.fullPath(), " ", "-", -1)
+}
+
+func (cmd *Command) docChildren() []*Command {
+	children := make([]*Command, 0, len(cmd.children))
+	for _, child := range cmd.children {
+		if !child.isHidden() {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// docText returns Text of the command with trailing newlines trimmed
+func (cmd *Command) docText() string {
+	return strings.TrimRight(cmd.Text, "\n")
+}
+
+// docValueName returns name of the flag's value shown in documents
+func (fl *flag) docValueName() string {
+	if fl.tag.name != "" {
+		return fl.tag.name
+	}
+	if fl.isBoolean() || fl.isCounter() {
+		return ""
+	}
+	return "VALUE"
+}
+
+// docNotes returns notes about how to use the flag, values are quoted by
+// quote
+func (fl *flag) docNotes(quote func(string) string) []string {
+	var notes []string
+	if fl.tag.isRequired {
+		notes = append(notes, "Required")
+	}
+	if fl.tag.isForce {
+		notes = append(notes, "Required flags aren't checked if given")
+	}
+	if fl.tag.dft != "" {
+		notes = append(notes, "Default: "+quote(fl.tag.dft))
+	}
+	if len(fl.tag.choices) > 0 {
+		choices := make([]string, 0, len(fl.tag.choices))
+		for _, choice := range fl.tag.choices {
+			choices = append(choices, quote(choice))
+		}
+		notes = append(notes, "One of: "+strings.Join(choices, ", "))
+	}
+	if fl.isSlice() || fl.isMap() {
+		notes = append(notes, "May be given more than once")
+	}
+	if fl.tag.isPassword {
+		notes = append(notes, "Read without echo if not given")
+	} else if fl.tag.isEdit {
+		notes = append(notes, "Edited in editor if not given")
+	} else if fl.tag.prompt != "" {
+		notes = append(notes, "Prompted if not given")
+	}
+	return notes
+}
+
+// docEnv is an environment variable documented
+type docEnv struct {
+	name string
+	desc string
+}
+
+var envRefRegexp = regexp.MustCompile(`\$\$|\$(\w+)`)
+
+// docEnvs returns environment variables referenced by default values of
+// flags and used by editors, and variables of the package for root
+func (cmd *Command) docEnvs(fs flagSlice) []docEnv {
+	var (
+		envs    []docEnv
+		indexes = make(map[string]int)
+		add     = func(name, desc string) {
+			if i, ok := indexes[name]; ok {
+				envs[i].desc += ", " + desc
+				return
+			}
+			indexes[name] = len(envs)
+			envs = append(envs, docEnv{name, desc})
+		}
+		hasEdit = false
+	)
+	for _, fl := range fs {
+		for _, match := range envRefRegexp.FindAllStringSubmatch(fl.tag.dft, -1) {
+			name := match[1]
+			if name == "" || name == builtinVar_EXEC_PATH || name == builtinVar_EXEC_FILENAME {
+				continue
+			}
+			add(name, "default of "+fl.tag.names()[len(fl.tag.names())-1])
+		}
+		hasEdit = hasEdit || fl.tag.isEdit
+	}
+	if hasEdit {
+		add("VISUAL", "editor of edit flags")
+		add("EDITOR", "editor of edit flags if VISUAL is empty")
+	}
+	if cmd.parent == nil {
+		add(NoInputEnv, "disables prompts and editors if true")
+		add(DebugEnv, "prints stack of recovered panics if true")
+	}
+	return envs
+}
+
+// roffEscape escapes s as text of roff
+func roffEscape(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffQuote escapes s as a quoted argument of roff request
+func roffQuote(s string) string {
+	return `"` + strings.Replace(roffEscape(s), `"`, `\(dq`, -1) + `"`
+}
+
+// roffParagraphs escapes s and separates paragraphs by blank lines
+func roffParagraphs(s string) string {
+	paragraphs := strings.Split(strings.TrimSpace(s), "\n\n")
+	for i, p := range paragraphs {
+		paragraphs[i] = roffEscape(strings.Trim(p, "\n"))
+	}
+	return strings.Join(paragraphs, "\n.PP\n")
+}
+
+// markdownCell escapes s as a cell of Markdown table
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(strings.TrimSpace(s), "\n", "<br>", -1)
+}
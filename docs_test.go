@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDocsTree() *Command {
+	type rootT struct {
+		Helper
+	}
+	type deployT struct {
+		Env    string   `cli:"*e,env" usage:"environment" choices:"dev,prod"`
+		Dir    string   `cli:"dir" usage:"working directory" dft:"$HOME/app"`
+		Tags   []string `cli:"tag" name:"TAG" usage:"tags | labels"`
+		DryRun bool     `cli:"dry-run" usage:"print only"`
+	}
+	root := &Command{
+		Name:   "app",
+		Desc:   "app manages apps",
+		Global: true,
+		Argv:   func() interface{} { return new(rootT) },
+		Fn:     donothing,
+	}
+	root.Register(&Command{
+		Name:    "deploy",
+		Aliases: []string{"dp"},
+		Desc:    "deploy app",
+		Text:    "Deploys the app.\n\n.Runs hooks first.",
+		Argv:    func() interface{} { return new(deployT) },
+		Fn:      donothing,
+	})
+	root.Register(&Command{Name: "_hidden", Fn: donothing})
+	root.Register(DocsCommand("generate documents"))
+	return root
+}
+
+func TestGenMan(t *testing.T) {
+	root := newDocsTree()
+	buf := new(bytes.Buffer)
+	header := &ManHeader{Date: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), Source: "app 1.0", Manual: "App Manual"}
+	require.Nil(t, GenMan(root.Route([]string{"deploy"}), buf, header))
+	assert.Equal(t, `.TH "APP\-DEPLOY" "1" "March 2020" "app 1.0" "App Manual"
+.SH NAME
+app\-deploy \- deploy app
+.SH SYNOPSIS
+app deploy \-\-env=VALUE [OPTIONS]
+.SH DESCRIPTION
+Deploys the app.
+.PP
+\&.Runs hooks first.
+.SH ALIASES
+dp
+.SH OPTIONS
+.TP
+\fB\-h\fR, \fB\-\-help\fR
+display help information
+.br
+Required flags aren't checked if given.
+.TP
+\fB\-e\fR, \fB\-\-env\fR=\fIVALUE\fR
+environment
+.br
+Required. One of: dev, prod.
+.TP
+\fB\-\-dir\fR=\fIVALUE\fR
+working directory
+.br
+Default: $HOME/app.
+.TP
+\fB\-\-tag\fR=\fITAG\fR
+tags | labels
+.br
+May be given more than once.
+.TP
+\fB\-\-dry\-run\fR
+print only
+.SH ENVIRONMENT
+.TP
+\fBHOME\fR
+default of \-\-dir
+.SH SEE ALSO
+.BR app (1)
+`, buf.String())
+
+	buf.Reset()
+	require.Nil(t, GenMan(root, buf, nil))
+	assert.Contains(t, buf.String(), ".SH COMMANDS\n.TP\n\\fBdeploy\\fR\ndeploy app\n.TP\n\\fBdocs\\fR\ngenerate documents\n")
+	assert.Contains(t, buf.String(), "\\fBCLI_NO_INPUT\\fR\n")
+	assert.Contains(t, buf.String(), ".SH SEE ALSO\n.BR app\\-deploy (1),\n.BR app\\-docs (1)\n")
+	assert.NotContains(t, buf.String(), "hidden")
+}
+
+func TestGenMarkdownTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-docs")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	root := newDocsTree()
+	require.Nil(t, root.RunWith([]string{"docs", "markdown", dir}, nil, nil))
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.Nil(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "# app\n\napp manages apps\n\n## Commands\n\n"+
+		"- [app](app.md): app manages apps\n"+
+		"  - [app deploy](app-deploy.md): deploy app\n"+
+		"  - [app docs](app-docs.md): generate documents\n", read("index.md"))
+	deploy := read("app-deploy.md")
+	assert.Contains(t, deploy, "# app deploy\n\ndeploy app\n\n## Synopsis\n\n```\napp deploy --env=VALUE [OPTIONS]\n```\n\n")
+	assert.Contains(t, deploy, "Aliases: `dp`\n")
+	assert.Contains(t, deploy, "| `-e, --env=VALUE` | environment<br>Required. One of: `dev`, `prod`. |\n")
+	assert.Contains(t, deploy, "| `--tag=TAG` | tags \\| labels<br>May be given more than once. |\n")
+	assert.Contains(t, deploy, "## Environment\n\n- `HOME`: default of --dir\n")
+	assert.True(t, len(deploy) > 0 && deploy[len(deploy)-1] == '\n')
+	assert.Contains(t, deploy, "## See also\n\n- [app](app.md): app manages apps\n")
+	assert.Contains(t, read("app.md"), "| [deploy](app-deploy.md) | deploy app |\n")
+	_, err = os.Stat(filepath.Join(dir, "app-_hidden.md"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.Nil(t, root.RunWith([]string{"docs", "man", dir}, nil, nil))
+	assert.Contains(t, read("app-docs.1"), ".TH \"APP\\-DOCS\" \"1\"")
+	assert.Error(t, root.RunWith([]string{"docs", "pdf", dir}, nil, nil))
+}
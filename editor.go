@@ -1,53 +1,184 @@
 package cli
 
 import (
-	"crypto/rand"
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
+// DefaultEditor is the editor program used if no editor specified
 const DefaultEditor = "vim"
 
-// GetEditor sets callback to get editor program
+// GetEditor sets callback to get editor program of the default app
+//
+// Deprecated: set Editor of App, or of DefaultApp() for package-level
+// functions. Other apps never use it.
 var GetEditor func() (string, error)
 
-func getEditor() (string, error) {
-	if GetEditor != nil {
-		return GetEditor()
-	}
-	return exec.LookPath(DefaultEditor)
+// commentPrefix starts a line which is removed from content of edit flags
+const commentPrefix = "#"
+
+// commentSyntax is syntax of comments stripped from content of edit flags,
+// end is empty for line comments
+type commentSyntax struct {
+	start, end string
 }
 
-func randomFilename() string {
-	buf := make([]byte, 16)
-	if _, err := rand.Read(buf); err != nil {
-		return "CLI_EDIT_FILE"
+// commentSyntaxOf returns comment syntax of file type of filename, so that
+// lines like Markdown headings are kept. HTML comments are used for
+// Markdown, HTML and XML, and lines starting with '#' for others.
+func commentSyntaxOf(filename string) commentSyntax {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown", ".html", ".htm", ".xml":
+		return commentSyntax{start: "<!--", end: "-->"}
 	}
-	return fmt.Sprintf(".%x", buf)
+	return commentSyntax{start: commentPrefix}
 }
 
-// LaunchEditor launchs the specified editor with a random filename
+// LaunchEditor launchs the specified editor with a temporary file
 func LaunchEditor(editor string) (content []byte, err error) {
-	return launchEditorWithFilename(editor, randomFilename())
+	return editFile(nil, editor, "", nil, newConsole(os.Stdin, os.Stdout, os.Stderr))
+}
+
+// tempPattern returns pattern of temporary file, name of hint is kept as
+// suffix so that editor could detect file type by extension
+func tempPattern(hint string) string {
+	if hint == "" {
+		return "cli-edit-*"
+	}
+	return "cli-edit-*-" + filepath.Base(hint)
+}
+
+// splitCommand splits editor command into words, quotes group words
+// containing spaces, e.g. `code --wait` and `"/path/to/my editor" -w`
+func splitCommand(s string) []string {
+	var (
+		words   []string
+		word    bytes.Buffer
+		quote   rune
+		inWord  bool
+		flushFn = func() {
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		}
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			flushFn()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	flushFn()
+	return words
 }
 
-func launchEditorWithFilename(editor, filename string) (content []byte, err error) {
-	cmd := exec.Command(editor, filename)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// editFile writes content to a temporary file under os.TempDir, edits it
+// with editor and returns content of the file. The file is named after hint
+// and removed finally.
+func editFile(app *App, editor, hint string, content []byte, cons *console) ([]byte, error) {
+	file, err := ioutil.TempFile("", tempPattern(hint))
+	if err != nil {
+		return nil, err
+	}
+	filename := file.Name()
 	defer os.Remove(filename)
-	err = cmd.Run()
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if app != nil && app.LaunchEditor != nil {
+		err = app.LaunchEditor(editor, filename)
+	} else {
+		words := splitCommand(editor)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("editor is empty")
+		}
+		cmd := exec.Command(words[0], append(words[1:], filename)...)
+		cmd.Stdin = cons.stdin
+		cmd.Stdout = cons.stdout
+		cmd.Stderr = cons.stderr
+		err = cmd.Run()
+	}
 	if err != nil {
 		if _, isExitError := err.(*exec.ExitError); !isExitError {
-			return
+			return nil, err
 		}
 	}
 	content, err = ioutil.ReadFile(filename)
 	if err != nil {
 		return []byte{}, nil
 	}
-	return
+	return content, nil
+}
+
+// editTemplate returns initial content of edit flag, which is followed by
+// comments about how to edit it
+func editTemplate(value, what string, syntax commentSyntax) string {
+	var buf bytes.Buffer
+	buf.WriteString(value)
+	if value != "" && !strings.HasSuffix(value, "\n") {
+		buf.WriteByte('\n')
+	}
+	if syntax.end == "" {
+		fmt.Fprintf(&buf, "\n%s Please enter %s. Lines starting with '%s' will be ignored,\n", syntax.start, what, syntax.start)
+		fmt.Fprintf(&buf, "%s and an empty content aborts.\n", syntax.start)
+	} else {
+		fmt.Fprintf(&buf, "\n%s Please enter %s. This comment will be ignored,\n", syntax.start, what)
+		fmt.Fprintf(&buf, "and an empty content aborts. %s\n", syntax.end)
+	}
+	return buf.String()
+}
+
+// stripComments removes comments, leading and trailing blank lines and
+// trailing spaces of content
+func stripComments(content string, syntax commentSyntax) string {
+	if syntax.end != "" {
+		var buf strings.Builder
+		for {
+			i := strings.Index(content, syntax.start)
+			if i < 0 {
+				break
+			}
+			buf.WriteString(content[:i])
+			j := strings.Index(content[i:], syntax.end)
+			if j < 0 {
+				content = ""
+				break
+			}
+			content = content[i+j+len(syntax.end):]
+		}
+		buf.WriteString(content)
+		content = buf.String()
+	}
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if syntax.end != "" || !strings.HasPrefix(line, syntax.start) {
+			kept = append(kept, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return strings.Trim(strings.Join(kept, "\n"), "\n")
 }
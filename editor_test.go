@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		words []string
+	}{
+		{"vim", []string{"vim"}},
+		{"  code   --wait ", []string{"code", "--wait"}},
+		{`"/path/to/my editor" -w ''`, []string{"/path/to/my editor", "-w", ""}},
+		{"emacs -nw 'a b'c", []string{"emacs", "-nw", "a bc"}},
+		{"", nil},
+	} {
+		assert.Equal(t, tt.words, splitCommand(tt.s), tt.s)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	hash := commentSyntaxOf("")
+	assert.Equal(t, "hello\n  # not a comment\n\nworld", stripComments("\n# comment\nhello  \n  # not a comment\n\nworld\n\n# comment\n", hash))
+	assert.Equal(t, "", stripComments("\n# comment\n\n", hash))
+
+	// headings are kept in Markdown
+	md := commentSyntaxOf("MSG.md")
+	assert.Equal(t, "# Title\n\nbody", stripComments("# Title\n\nbody\n\n<!-- Please enter message.\nand an empty content aborts. -->\n", md))
+	assert.Equal(t, "a  b", stripComments("a <!-- x --> b<!-- unclosed\n", md))
+}
+
+func TestEditFlag(t *testing.T) {
+	type argT struct {
+		Msg   string `edit:"*m" usage:"message"`
+		Note  string `edit:"NOTE.md:note"`
+		Draft string `edit:"draft" dft:"draft"`
+	}
+	var (
+		argv    *argT
+		editors []string
+		files   []string
+		prefill []string
+		edits   []string
+	)
+	app := NewApp(&Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			argv = ctx.Argv().(*argT)
+			return nil
+		},
+	})
+	app.Stdin, app.Stdout, app.Stderr = new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	app.Getenv = func(key string) string {
+		return map[string]string{"VISUAL": "code --wait", "EDITOR": "nano"}[key]
+	}
+	app.LaunchEditor = func(editor, filename string) error {
+		data, err := ioutil.ReadFile(filename)
+		require.Nil(t, err)
+		editors = append(editors, editor)
+		files = append(files, filename)
+		prefill = append(prefill, string(data))
+		content := edits[0]
+		edits = edits[1:]
+		return ioutil.WriteFile(filename, []byte(content), 0600)
+	}
+
+	edits = []string{"# ignored\nfix bug\n", "# Done\n<!-- ignored -->\n", "draft v2\n"}
+	require.Nil(t, app.Execute(nil))
+	assert.Equal(t, &argT{Msg: "fix bug", Note: "# Done", Draft: "draft v2"}, argv)
+	assert.Equal(t, []string{"code --wait", "code --wait", "code --wait"}, editors)
+	assert.Equal(t, filepath.Clean(os.TempDir()), filepath.Dir(files[0]))
+	assert.True(t, strings.HasSuffix(files[1], "-NOTE.md"), files[1])
+	assert.Equal(t, "\n# Please enter message. Lines starting with '#' will be ignored,\n# and an empty content aborts.\n", prefill[0])
+	assert.Equal(t, "\n<!-- Please enter --note. This comment will be ignored,\nand an empty content aborts. -->\n", prefill[1])
+	// default value is prefilled
+	assert.Equal(t, "draft\n\n# Please enter --draft. Lines starting with '#' will be ignored,\n# and an empty content aborts.\n", prefill[2])
+	for _, file := range files {
+		_, err := os.Stat(file)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// given flags are not edited
+	edits = nil
+	require.Nil(t, app.Execute([]string{"-m", "msg", "--note", "note", "--draft", "draft"}))
+	assert.Equal(t, &argT{Msg: "msg", Note: "note", Draft: "draft"}, argv)
+
+	// empty content of optional flags aborts, too
+	edits = []string{"<!-- nothing -->\n"}
+	err := app.Execute([]string{"-m", "msg", "--draft", "draft"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "aborting due to empty --note")
+
+	// empty content of required flags aborts
+	edits = []string{"# nothing\n\n"}
+	err = app.Execute([]string{"--note", "note", "--draft", "draft"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "aborting due to empty -m")
+}
+
+func TestGetEditor(t *testing.T) {
+	GetEditor = func() (string, error) { return "legacy", nil }
+	defer func() { GetEditor = nil }()
+
+	// only default app uses the deprecated GetEditor
+	editor, err := defaultApp.editor()
+	require.Nil(t, err)
+	assert.Equal(t, "legacy", editor)
+
+	app := NewApp(nil)
+	app.Getenv = func(key string) string { return map[string]string{"EDITOR": "nano"}[key] }
+	editor, err = app.editor()
+	require.Nil(t, err)
+	assert.Equal(t, "nano", editor)
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net/http"
+	"runtime/debug"
 	"strings"
 
 	"github.com/labstack/gommon/color"
@@ -13,11 +15,35 @@ var (
 	errNotAPointerToStruct = errors.New("not a pointer to struct")
 	errNotAPointer         = errors.New("argv is not a pointer")
 	errCliTagTooMany       = errors.New("cli tag too many")
+	errProviderPanicked    = errors.New("provider panicked")
 )
 
+// Exit codes used by default exit code mapping
+const (
+	ExitOK       = 0
+	ExitFailure  = 1
+	ExitUsage    = 2
+	ExitNotFound = 127
+)
+
+// ExitCoder represents an error which carries an exit code
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
 type (
 	exitError struct{}
 
+	exitCodeError struct {
+		code int
+		msg  string
+	}
+
+	usageError struct {
+		err error
+	}
+
 	commandNotFoundError struct {
 		command string
 	}
@@ -45,10 +71,91 @@ type (
 )
 
 func (e exitError) Error() string { return "exit" }
+func (e exitError) ExitCode() int { return ExitOK }
 
 // ExitError is a special error, should be ignored but return
 var ExitError = exitError{}
 
+// Exit returns an error which makes the app exit with code,
+// msg would be written to stderr if it's not empty
+func Exit(code int, msg string) error {
+	return exitCodeError{code: code, msg: msg}
+}
+
+func (e exitCodeError) Error() string { return e.msg }
+func (e exitCodeError) ExitCode() int { return e.code }
+
+// errUsageShown reports a usage error whose usage has been written
+var errUsageShown = Exit(ExitUsage, "")
+
+// isSilentError reports whether err should be returned without any message
+func isSilentError(err error) bool {
+	e, ok := err.(exitCodeError)
+	return ok && e.msg == ""
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+func (e usageError) ExitCode() int {
+	if coder, ok := e.err.(ExitCoder); ok {
+		return coder.ExitCode()
+	}
+	return ExitUsage
+}
+
+// PanicError is a panic recovered from a command or a validator
+type PanicError struct {
+	Value interface{} // value passed to panic
+	Stack []byte      // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("panic: %v", e.Value) }
+
+// catchPanic recovers a panic into a PanicError stored in err
+func catchPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = &PanicError{Value: v, Stack: debug.Stack()}
+	}
+}
+
+// ExitCodeOf returns the exit code of err by default mapping:
+// nil and ExitError map to ExitOK, ExitCoder maps to it's ExitCode,
+// usage errors map to ExitUsage, a not found command maps to ExitNotFound
+// and any other error maps to ExitFailure.
+func ExitCodeOf(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return ExitFailure
+}
+
+// httpStatusOf returns HTTP status code of err
+func httpStatusOf(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if werr, ok := err.(wrapError); ok {
+		err = werr.err
+	}
+	switch err.(type) {
+	case commandNotFoundError:
+		return http.StatusNotFound
+	case methodNotAllowedError:
+		return http.StatusMethodNotAllowed
+	case usageError:
+		return http.StatusBadRequest
+	case exitCodeError:
+		if isSilentError(err) {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
+
 func throwCommandNotFound(command string) commandNotFoundError {
 	return commandNotFoundError{command: command}
 }
@@ -65,10 +172,14 @@ func (e commandNotFoundError) Error() string {
 	return fmt.Sprintf("command %s not found", e.command)
 }
 
+func (e commandNotFoundError) ExitCode() int { return ExitNotFound }
+
 func (e methodNotAllowedError) Error() string {
 	return fmt.Sprintf("method %s not allowed", e.method)
 }
 
+func (e methodNotAllowedError) ExitCode() int { return ExitUsage }
+
 func (e routerRepeatError) Error() string {
 	return fmt.Sprintf("router %s repeat", e.router)
 }
@@ -77,6 +188,10 @@ func (e wrapError) Error() string {
 	return e.msg
 }
 
+func (e wrapError) Unwrap() error {
+	return e.err
+}
+
 func wrapErr(err error, appendString string, clr color.Color) error {
 	if err == nil {
 		return err
@@ -292,9 +292,9 @@ func ExampleNumArgFunc_exactN() {
 	fmt.Println(app().Run([]string{"-i", "1", "b", "c"}))
 
 	// Output:
-	// usage function<nil>
+	// usage function
 	// <nil>
-	// usage function<nil>
+	// usage function
 }
 
 func ExampleNumArgFunc_atLeast() {
@@ -319,7 +319,7 @@ func ExampleNumArgFunc_atLeast() {
 	fmt.Println(app().Run([]string{"-i", "1", "b", "c"}))
 
 	// Output:
-	// usage function<nil>
+	// usage function
 	// <nil>
 	// <nil>
 }
@@ -350,7 +350,7 @@ func ExampleNumArgFunc_atMost() {
 	// <nil>
 	// <nil>
 	// <nil>
-	// usage function<nil>
+	// usage function
 }
 
 func ExampleNumOptionFunc_exactN() {
@@ -374,7 +374,7 @@ func ExampleNumOptionFunc_exactN() {
 	fmt.Println(app().Run([]string{"-i", "1"}))
 
 	// Output:
-	// usage function<nil>
+	// usage function
 	// <nil>
 }
 
@@ -399,7 +399,7 @@ func ExampleNumOptionFunc_atLeast() {
 	fmt.Println(app().Run([]string{"-i", "1"}))
 
 	// Output:
-	// usage function<nil>
+	// usage function
 	// <nil>
 }
 
@@ -431,7 +431,7 @@ func ExampleNumOptionFunc_atMost() {
 	// <nil>
 	// <nil>
 	// <nil>
-	// usage function<nil>
+	// usage function
 }
 
 type config1 struct {
@@ -474,6 +474,8 @@ func ExampleHelper() {
 		return nil
 	})
 	// Output:
+	// Usage: app [OPTIONS]
+	//
 	// Options:
 	//
 	//   -h, --help   display help information
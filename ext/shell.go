@@ -3,129 +3,561 @@ package ext
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"text/template"
+	"runtime"
+	"strings"
 
 	"github.com/mkideal/cli"
 )
 
-// InstallBashCompletion install bash_completion
+// CompletionOptions configures InstallCompletion and UninstallCompletion
+type CompletionOptions struct {
+	// Shell is one of cli.CompletionShells, detected by $SHELL if empty
+	Shell string
+	// System installs the script into the system-wide completion directory
+	// (the first of $XDG_DATA_DIRS) instead of the user's, which usually
+	// requires root and never touches startup files
+	System bool
+	// DryRun writes a diff of files to Stdout instead of changing them
+	DryRun bool
+	// Stdout receives the diff of a dry run and notes of startup files not
+	// found, os.Stdout used if nil
+	Stdout io.Writer
+	// Getenv looks up HOME, SHELL, ZDOTDIR and XDG directories,
+	// os.Getenv used if nil
+	Getenv func(key string) string
+}
+
+func (opts CompletionOptions) getenv(key string) string {
+	if opts.Getenv != nil {
+		return opts.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func (opts CompletionOptions) stdout() io.Writer {
+	if opts.Stdout != nil {
+		return opts.Stdout
+	}
+	return os.Stdout
+}
+
+// DetectShell returns the user's shell by $SHELL, which is one of
+// cli.CompletionShells or empty if unknown
+func DetectShell(getenv func(key string) string) string {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	shell := strings.TrimSuffix(filepath.Base(getenv("SHELL")), ".exe")
+	switch shell {
+	case "bash", "zsh", "fish":
+		return shell
+	case "pwsh", "powershell":
+		return "powershell"
+	}
+	if shell == "." && runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return ""
+}
+
+// InstallCompletion installs completion script of root for the shell.
+// The script is written to the completion directory of the shell, and a
+// block between markers which loads it is added to the startup file if the
+// shell doesn't load it automatically. Startup files are never created, a
+// note of the line to add is written to Stdout if it doesn't exist.
+// Installing again updates the script and the block in place, and
+// UninstallCompletion removes both. Files are written atomically.
+//
+// Files written by InstallBashCompletion of old versions are removed.
+func InstallCompletion(root *cli.Command, opts CompletionOptions) error {
+	return applyCompletion(root, opts, true)
+}
+
+// UninstallCompletion removes files and blocks added by InstallCompletion
+func UninstallCompletion(root *cli.Command, opts CompletionOptions) error {
+	return applyCompletion(root, opts, false)
+}
+
+// InstallBashCompletion installs bash completion of root for current user
+//
+// Deprecated: use InstallCompletion, which supports more shells and could
+// be undone by UninstallCompletion.
 func InstallBashCompletion(root *cli.Command) error {
+	return InstallCompletion(root, CompletionOptions{Shell: "bash"})
+}
+
+func applyCompletion(root *cli.Command, opts CompletionOptions, install bool) error {
 	if root.Name == "" {
 		return fmt.Errorf("root command's name is empty")
 	}
-	compFilename := "." + root.Name + "_compeltion"
-	compFilepath := filepath.Join(os.Getenv("HOME"), compFilename)
-	compFile, err := os.OpenFile(compFilepath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0666)
+	shell := opts.Shell
+	if shell == "" {
+		if shell = DetectShell(opts.getenv); shell == "" {
+			return fmt.Errorf("couldn't detect shell, please specify one of %s", strings.Join(cli.CompletionShells, ", "))
+		}
+	}
+	target, err := completionTargetOf(root.Name, shell, opts)
 	if err != nil {
 		return err
 	}
-	buff, err := genBashCompletion(root)
+	changes, err := target.changes(root.Name, shell, install)
 	if err != nil {
 		return err
 	}
-	if _, err := compFile.Write(buff.Bytes()); err != nil {
-		return err
+	if install && target.rc != "" {
+		if _, err := os.Stat(target.rc); os.IsNotExist(err) {
+			fmt.Fprintf(opts.stdout(), "%s not found, add following line to it to load completion:\n\n\t%s\n\n", target.rc, target.source)
+		}
+	}
+	for _, c := range changes {
+		if opts.DryRun {
+			err = c.writeDiff(opts.stdout())
+		} else {
+			err = c.apply()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completionTarget locates files of completion
+type completionTarget struct {
+	script string   // file of the completion script
+	rc     string   // startup file loading the script, empty if not needed
+	source string   // line loading the script in rc
+	legacy []string // files of old versions sourced by legacyRCs
+}
+
+// legacyRCs are startup files which old versions added loading line to
+var legacyRCs = []string{".bashrc", ".bash_profile"}
+
+func completionTargetOf(name, shell string, opts CompletionOptions) (*completionTarget, error) {
+	if opts.System {
+		dataDir := "/usr/local/share"
+		if dirs := opts.getenv("XDG_DATA_DIRS"); dirs != "" {
+			dataDir = filepath.SplitList(dirs)[0]
+		}
+		switch shell {
+		case "bash":
+			return &completionTarget{script: filepath.Join(dataDir, "bash-completion", "completions", name)}, nil
+		case "zsh":
+			return &completionTarget{script: filepath.Join(dataDir, "zsh", "site-functions", "_"+name)}, nil
+		case "fish":
+			return &completionTarget{script: filepath.Join(dataDir, "fish", "vendor_completions.d", name+".fish")}, nil
+		case "powershell":
+			return nil, fmt.Errorf("system-wide completion unsupported for powershell")
+		}
+		return nil, unsupportedShell(shell)
 	}
 
-	bashrcFiles := []string{
-		".bashrc",
-		".bash_profile",
+	home := opts.getenv("HOME")
+	if home == "" && runtime.GOOS == "windows" {
+		home = opts.getenv("USERPROFILE")
+	}
+	if home == "" {
+		return nil, fmt.Errorf("home directory not found")
+	}
+	dataHome := opts.getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
 	}
-	var dstFile *os.File
-	for _, bashFilename := range bashrcFiles {
-		dstFilepath := filepath.Join(os.Getenv("HOME"), bashFilename)
-		dstFile, err = os.OpenFile(dstFilepath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
-		if err == nil {
+	configHome := opts.getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	switch shell {
+	case "bash":
+		script := filepath.Join(dataHome, "bash-completion", "completions", name)
+		target := &completionTarget{
+			script: script,
+			rc:     filepath.Join(home, legacyRCs[0]),
+			source: fmt.Sprintf("[ -f %s ] && . %s", shellQuote(script), shellQuote(script)),
+			legacy: []string{filepath.Join(home, "."+name+"_compeltion")},
+		}
+		// keep the startup file the user has
+		for _, rc := range legacyRCs {
+			if _, err := os.Stat(filepath.Join(home, rc)); err == nil {
+				target.rc = filepath.Join(home, rc)
+				break
+			}
+		}
+		return target, nil
+	case "zsh":
+		script := filepath.Join(dataHome, "zsh", "site-functions", "_"+name)
+		zdotdir := opts.getenv("ZDOTDIR")
+		if zdotdir == "" {
+			zdotdir = home
+		}
+		return &completionTarget{
+			script: script,
+			rc:     filepath.Join(zdotdir, ".zshrc"),
+			source: fmt.Sprintf("(( $+functions[compdef] )) && [ -f %s ] && source %s", shellQuote(script), shellQuote(script)),
+		}, nil
+	case "fish":
+		// fish loads completions from the directory lazily
+		return &completionTarget{script: filepath.Join(configHome, "fish", "completions", name+".fish")}, nil
+	case "powershell":
+		script := filepath.Join(dataHome, "powershell", "completions", name+".ps1")
+		profile := filepath.Join(configHome, "powershell", "Microsoft.PowerShell_profile.ps1")
+		if runtime.GOOS == "windows" {
+			profile = filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+		}
+		quoted := "'" + strings.Replace(script, "'", "''", -1) + "'"
+		return &completionTarget{
+			script: script,
+			rc:     profile,
+			source: fmt.Sprintf("if (Test-Path %s) { . %s }", quoted, quoted),
+		}, nil
+	}
+	return nil, unsupportedShell(shell)
+}
+
+func unsupportedShell(shell string) error {
+	return fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(cli.CompletionShells, ", "))
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// changes returns changes of files to install or uninstall completion
+func (target *completionTarget) changes(name, shell string, install bool) ([]*fileChange, error) {
+	var changes []*fileChange
+	add := func(path string, fn func(old []byte) []byte) error {
+		c, err := newFileChange(path)
+		if err != nil {
+			return err
+		}
+		if c.exists {
+			c.new = fn(c.old)
+		} else {
+			c.new = fn(nil)
+		}
+		if c.changed() {
+			changes = append(changes, c)
+		}
+		return nil
+	}
+
+	var script []byte
+	if install {
+		s, err := cli.CompletionScript(name, shell)
+		if err != nil {
+			return nil, err
+		}
+		script = []byte(s)
+	}
+	if err := add(target.script, func([]byte) []byte { return script }); err != nil {
+		return nil, err
+	}
+	if target.rc != "" {
+		begin, end := "# >>> "+name+" completion >>>", "# <<< "+name+" completion <<<"
+		body := ""
+		if install {
+			body = target.source
+		}
+		err := add(target.rc, func(old []byte) []byte {
+			// never create startup files
+			if old == nil {
+				return nil
+			}
+			return setBlock(old, begin, end, body)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// clean up files of old versions
+	for _, legacy := range target.legacy {
+		if err := add(legacy, func([]byte) []byte { return nil }); err != nil {
+			return nil, err
+		}
+		base := filepath.Base(legacy)
+		line := fmt.Sprintf("[ -f ~/%s ] && . ~/%s", base, base)
+		for _, rc := range legacyRCs {
+			rc = filepath.Join(filepath.Dir(legacy), rc)
+			// the startup file may be changed already
+			var pending *fileChange
+			for _, c := range changes {
+				if c.path == rc {
+					pending = c
+				}
+			}
+			if pending != nil {
+				pending.new = removeLegacyLine(pending.new, line)
+				continue
+			}
+			err := add(rc, func(old []byte) []byte {
+				if old == nil {
+					return nil
+				}
+				return removeLegacyLine(old, line)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// setBlock replaces lines between begin and end markers in data with body,
+// the block is appended if not found and removed if body is empty
+func setBlock(data []byte, begin, end, body string) []byte {
+	lines := splitLines(data)
+	start, stop := -1, -1
+	for i, line := range lines {
+		if start < 0 && line == begin {
+			start = i
+		} else if start >= 0 && line == end {
+			stop = i
 			break
 		}
 	}
-	if dstFile == nil {
-		return fmt.Errorf("no destination bash file")
+	var block []string
+	if body != "" {
+		block = append(append([]string{begin}, splitLines([]byte(body))...), end)
+	}
+	if start >= 0 && stop >= 0 {
+		if block == nil && start > 0 && lines[start-1] == "" {
+			// remove the blank line added with the block
+			start--
+		}
+		lines = append(lines[:start], append(block, lines[stop+1:]...)...)
+	} else if block != nil {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	} else {
+		return data
+	}
+	return joinLines(lines)
+}
+
+// removeLegacyLine removes line added by InstallBashCompletion of old
+// versions with the comment before it
+func removeLegacyLine(data []byte, line string) []byte {
+	lines := splitLines(data)
+	removed := false
+	for i := 0; i < len(lines); i++ {
+		if lines[i] != line {
+			continue
+		}
+		removed = true
+		start := i
+		if start > 0 && lines[start-1] == "#Auto-generated by cli" {
+			start--
+			if start > 0 && lines[start-1] == "" {
+				start--
+			}
+		}
+		lines = append(lines[:start], lines[i+1:]...)
+		i = start - 1
 	}
-	data, err := ioutil.ReadAll(dstFile)
+	if !removed {
+		return data
+	}
+	return joinLines(lines)
+}
+
+func splitLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" && len(data) <= 1 {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func joinLines(lines []string) []byte {
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// fileChange changes content of a file, nil new content removes the file
+type fileChange struct {
+	path   string
+	exists bool
+	old    []byte
+	new    []byte
+}
+
+func newFileChange(path string) (*fileChange, error) {
+	c := &fileChange{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		c.exists, c.old = true, data
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *fileChange) changed() bool {
+	if c.new == nil {
+		return c.exists
+	}
+	return !c.exists || !bytes.Equal(c.old, c.new)
+}
+
+// apply writes or removes the file, the file is written to a temporary
+// file in the same directory which is then renamed to it
+func (c *fileChange) apply() error {
+	path := c.path
+	if c.exists {
+		// keep symbolic links of startup files managed by the user
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+	}
+	if c.new == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp*")
 	if err != nil {
 		return err
 	}
-
-	subslice := fmt.Sprintf("[ -f ~/%s ] && . ~/%s", compFilename, compFilename)
-	if !bytes.Contains(data, []byte(subslice)) {
-		_, err = dstFile.WriteString("\n#Auto-generated by cli\n" + subslice)
+	tmp := file.Name()
+	_, err = file.Write(c.new)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, mode)
 	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
 
+// writeDiff writes unified diff of the change
+func (c *fileChange) writeDiff(w io.Writer) error {
+	from, to := c.path, c.path
+	if !c.exists {
+		from = "/dev/null"
+	}
+	if c.new == nil {
+		to = "/dev/null"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, to)
+	writeHunks(&buf, diffLines(splitLines(c.old), splitLines(c.new)), 3)
+	_, err := w.Write(buf.Bytes())
 	return err
 }
 
-func genBashCompletion(root *cli.Command) (*bytes.Buffer, error) {
-	buff := bytes.NewBufferString("")
-	t, err := template.New("bash_completion").Parse(shellTemplateText)
-	if err != nil {
-		return nil, err
+// diffLines returns edit script from a to b by longest common subsequence,
+// every line is prefixed by ' ', '-' or '+'
+func diffLines(a, b []string) []string {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i, j = i+1, j+1
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	return lines
+}
+
+// writeHunks writes edit script as hunks with context lines around changes
+func writeHunks(w io.Writer, lines []string, context int) {
+	// line numbers of a and b before every edit
+	numA, numB := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, line := range lines {
+		numA[i+1], numB[i+1] = numA[i], numB[i]
+		if line[0] != '+' {
+			numA[i+1]++
+		}
+		if line[0] != '-' {
+			numB[i+1]++
+		}
+	}
+	for i := 0; i < len(lines); {
+		if lines[i][0] == ' ' {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk while changes are close enough
+		end := i
+		for k := i; k < len(lines) && k <= end+2*context; k++ {
+			if lines[k][0] != ' ' {
+				end = k
+			}
+		}
+		stop := end + context + 1
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n",
+			hunkRange(numA[start], numA[stop]-numA[start]),
+			hunkRange(numB[start], numB[stop]-numB[start]))
+		for _, line := range lines[start:stop] {
+			fmt.Fprintln(w, line)
+		}
+		i = stop
 	}
-	return buff, t.Execute(buff, struct {
-		Cli        string
-		CompleteFn string
-	}{Cli: root.Name, CompleteFn: genCompleteFn(root)})
-}
-
-func genCompleteFn(root *cli.Command) string {
-	return "TODO=true"
-}
-
-const shellTemplateText = `# {{.Cli}} command completion script
-
-COMP_WORDBREAKS=${COMP_WORDBREAKS/=/}
-COMP_WORDBREAKS=${COMP_WORDBREAKS/@/}
-export COMP_WORDBREAKS
-
-__complete_fn() {
-#COMP_CWORD
-#COMP_LINE
-#COMP_POINT
-#COMP_WORDS
-#{{.Cli}} completion -- "${COMP_WORDS[@]}"
-{{.CompleteFn}}
-}
-
-if type complete &>/dev/null; then
-  _{{.Cli}}_completion () {
-    local si="$IFS"
-    IFS=$'\n' COMPREPLY=($(__complete_fn \
-                           2>/dev/null)) || return $?
-    IFS="$si"
-  }
-  complete -F _{{.Cli}}_completion {{.Cli}}
-elif type compdef &>/dev/null; then
-  _{{.Cli}}_completion() {
-    si=$IFS
-    compadd -- $(COMP_CWORD=$((CURRENT-1)) \
-                 COMP_LINE=$BUFFER \
-                 COMP_POINT=0 \
-                 COMP_WORDS="${words[@]}" \
-				 __complete_fn \
-                 2>/dev/null)
-    IFS=$si
-  }
-  compdef _{{.Cli}}_completion {{.Cli}}
-elif type compctl &>/dev/null; then
-  _{{.Cli}}_completion () {
-    local cword line point words si
-    read -Ac words
-    read -cn cword
-    let cword-=1
-    read -l line
-    read -ln point
-    si="$IFS"
-    IFS=$'\n' reply=($(COMP_CWORD="$cword" \
-                       COMP_LINE="$line" \
-                       COMP_POINT="$point" \
-                       COMP_WORDS="${words[@]}" \
-					   __complete_fn \
-                       2>/dev/null)) || return $?
-    IFS="$si"
-  }
-  compctl -K _{{.Cli}}_completion {{.Cli}}
-fi
-`
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
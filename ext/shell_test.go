@@ -0,0 +1,132 @@
+package ext
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkideal/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectShell(t *testing.T) {
+	for shell, want := range map[string]string{
+		"/bin/bash":     "bash",
+		"/usr/bin/zsh":  "zsh",
+		"/usr/bin/fish": "fish",
+		"/usr/bin/pwsh": "powershell",
+		"/bin/tcsh":     "",
+	} {
+		assert.Equal(t, want, DetectShell(func(string) string { return shell }), shell)
+	}
+}
+
+func TestInstallCompletion(t *testing.T) {
+	home, err := ioutil.TempDir("", "cli-home")
+	require.Nil(t, err)
+	defer os.RemoveAll(home)
+	env := map[string]string{"HOME": home, "SHELL": "/bin/bash"}
+	opts := CompletionOptions{Getenv: func(key string) string { return env[key] }}
+	root := &cli.Command{Name: "app"}
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(home, name))
+		if os.IsNotExist(err) {
+			return "<none>"
+		}
+		require.Nil(t, err)
+		return string(data)
+	}
+
+	// files of old versions
+	require.Nil(t, ioutil.WriteFile(filepath.Join(home, ".app_compeltion"), []byte("old"), 0644))
+	bashrc := "export A=1\n\n#Auto-generated by cli\n[ -f ~/.app_compeltion ] && . ~/.app_compeltion"
+	require.Nil(t, ioutil.WriteFile(filepath.Join(home, ".bashrc"), []byte(bashrc), 0600))
+
+	// dry run changes nothing
+	diff := new(bytes.Buffer)
+	dryRun := opts
+	dryRun.DryRun, dryRun.Stdout = true, diff
+	require.Nil(t, InstallCompletion(root, dryRun))
+	assert.Equal(t, bashrc, read(".bashrc"))
+	assert.Equal(t, "<none>", read(".local/share/bash-completion/completions/app"))
+	script := filepath.Join(home, ".local/share/bash-completion/completions/app")
+	source := "[ -f '" + script + "' ] && . '" + script + "'"
+	assert.Contains(t, diff.String(), "--- /dev/null\n+++ "+script+"\n@@ -0,0 +1,")
+	assert.Contains(t, diff.String(), "--- "+home+"/.bashrc\n+++ "+home+"/.bashrc\n"+
+		"@@ -1,4 +1,5 @@\n export A=1\n \n-#Auto-generated by cli\n-[ -f ~/.app_compeltion ] && . ~/.app_compeltion\n"+
+		"+# >>> app completion >>>\n+"+source+"\n+# <<< app completion <<<\n")
+	assert.Contains(t, diff.String(), "--- "+home+"/.app_compeltion\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-old\n")
+
+	// install twice
+	for i := 0; i < 2; i++ {
+		require.Nil(t, InstallCompletion(root, opts))
+		assert.Equal(t, "export A=1\n\n# >>> app completion >>>\n"+source+"\n# <<< app completion <<<\n", read(".bashrc"))
+		assert.Contains(t, read(".local/share/bash-completion/completions/app"), "complete -o default -F _app_completion app")
+		assert.Equal(t, "<none>", read(".app_compeltion"))
+	}
+	info, err := os.Stat(filepath.Join(home, ".bashrc"))
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// nothing to change
+	diff.Reset()
+	require.Nil(t, InstallCompletion(root, dryRun))
+	assert.Equal(t, "", diff.String())
+
+	require.Nil(t, UninstallCompletion(root, opts))
+	assert.Equal(t, "export A=1\n", read(".bashrc"))
+	assert.Equal(t, "<none>", read(".local/share/bash-completion/completions/app"))
+	require.Nil(t, UninstallCompletion(root, opts))
+
+	// zsh honours ZDOTDIR and XDG_DATA_HOME
+	env["SHELL"], env["ZDOTDIR"], env["XDG_DATA_HOME"] = "/bin/zsh", filepath.Join(home, "zsh"), filepath.Join(home, "data")
+	zshSource := "source '" + filepath.Join(home, "data/zsh/site-functions/_app") + "'"
+
+	// startup files are never created
+	note := new(bytes.Buffer)
+	opts.Stdout = note
+	require.Nil(t, InstallCompletion(root, opts))
+	assert.Equal(t, "<none>", read("zsh/.zshrc"))
+	assert.True(t, strings.HasPrefix(read("data/zsh/site-functions/_app"), "#compdef app\n"))
+	assert.Contains(t, note.String(), filepath.Join(home, "zsh/.zshrc")+" not found")
+	assert.Contains(t, note.String(), zshSource)
+	require.Nil(t, UninstallCompletion(root, opts))
+	assert.Equal(t, "<none>", read("zsh/.zshrc"))
+	assert.Equal(t, "<none>", read("data/zsh/site-functions/_app"))
+
+	// startup files left empty are kept
+	require.Nil(t, os.Mkdir(filepath.Join(home, "zsh"), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(home, "zsh/.zshrc"), nil, 0644))
+	require.Nil(t, UninstallCompletion(root, opts))
+	assert.Equal(t, "", read("zsh/.zshrc"))
+	require.Nil(t, InstallCompletion(root, opts))
+	assert.Contains(t, read("zsh/.zshrc"), zshSource)
+	require.Nil(t, UninstallCompletion(root, opts))
+	assert.Equal(t, "", read("zsh/.zshrc"))
+
+	// so are ones emptied by removing lines of old versions
+	require.Nil(t, ioutil.WriteFile(filepath.Join(home, ".bash_profile"), []byte("#Auto-generated by cli\n[ -f ~/.app_compeltion ] && . ~/.app_compeltion\n"), 0644))
+	require.Nil(t, UninstallCompletion(root, CompletionOptions{Shell: "bash", Getenv: opts.Getenv}))
+	assert.Equal(t, "", read(".bash_profile"))
+
+	// fish loads the script itself
+	env["XDG_CONFIG_HOME"] = filepath.Join(home, "config")
+	opts.Shell = "fish"
+	require.Nil(t, InstallCompletion(root, opts))
+	assert.Contains(t, read("config/fish/completions/app.fish"), "complete -c app")
+
+	// system-wide directories
+	env["XDG_DATA_DIRS"] = filepath.Join(home, "usr") + string(os.PathListSeparator) + "/usr/share"
+	opts.System = true
+	require.Nil(t, InstallCompletion(root, opts))
+	assert.Contains(t, read("usr/fish/vendor_completions.d/app.fish"), "complete -c app")
+	opts.Shell = "powershell"
+	assert.Error(t, InstallCompletion(root, opts))
+	opts.Shell = "tcsh"
+	assert.EqualError(t, InstallCompletion(root, opts), `unsupported shell "tcsh", expected one of bash, zsh, fish, powershell`)
+}
@@ -11,14 +11,16 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/gommon/color"
-	"github.com/mkideal/expr"
 )
 
 type flag struct {
-	field reflect.StructField
-	value reflect.Value
+	app    *App
+	field  reflect.StructField
+	value  reflect.Value
+	schema *fieldSchema
 
 	// isAssigned indicates whether the flag is set(contains default value)
 	isAssigned bool
@@ -27,7 +29,10 @@ type flag struct {
 	isSet bool
 
 	// tag properties
-	tag tagProperty
+	tag *tagProperty
+
+	// parser for the flag, resolved by tag.parserName
+	parserCreator FlagParserCreator
 
 	// actual flag name
 	actualFlagName string
@@ -39,59 +44,65 @@ type flag struct {
 	//	-f xx -f yy -f zz
 	// `zz` is the last value
 	lastValue string
+
+	// isInputUnavailable indicates the flag should be prompted or edited,
+	// but interactive input is unavailable
+	isInputUnavailable bool
 }
 
-func newFlag(field reflect.StructField, value reflect.Value, tag *tagProperty, clr color.Color, dontSetValue bool) (fl *flag, err error) {
-	fl = &flag{field: field, value: value}
+func newFlag(app *App, fs *fieldSchema, value reflect.Value, clr color.Color, dontSetValue bool) (fl *flag, err error) {
+	fl = &flag{app: app, field: fs.field, value: value, schema: fs, tag: fs.tag}
 	if !fl.value.CanSet() {
 		return nil, fmt.Errorf("field %s can not set", clr.Bold(fl.field.Name))
 	}
-	fl.tag = *tag
 	if fl.isPtr() && fl.value.IsNil() {
 		fl.value.Set(reflect.New(fl.field.Type.Elem()))
 	}
-	isSliceDecoder := fl.value.Type().Implements(reflect.TypeOf((*SliceDecoder)(nil)).Elem())
-	if !isSliceDecoder && fl.value.CanAddr() {
-		isSliceDecoder = fl.value.Addr().Type().Implements(reflect.TypeOf((*SliceDecoder)(nil)).Elem())
+	if fl.tag.parserName != "" {
+		fl.parserCreator = app.lookupFlagParser(fl.tag.parserName)
 	}
-	fl.isNeedDelaySet = fl.tag.parserCreator != nil ||
-		(fl.field.Type.Kind() != reflect.Slice && fl.field.Type.Kind() != reflect.Map && !isSliceDecoder)
+	fl.isNeedDelaySet = fl.parserCreator != nil || fs.isNeedDelaySet
 	err = fl.init(clr, dontSetValue)
 	return
 }
 
 func (fl *flag) init(clr color.Color, dontSetValue bool) error {
-	var (
-		isNumber  = fl.isInteger() || fl.isFloat()
-		isDecoder = fl.value.Type().Implements(reflect.TypeOf((*Decoder)(nil)).Elem())
-		dft       string
-		err       error
-	)
-	if !isDecoder && fl.value.CanAddr() {
-		isDecoder = fl.value.Addr().Type().Implements(reflect.TypeOf((*Decoder)(nil)).Elem())
+	if dontSetValue || fl.tag.dft == "" {
+		return nil
 	}
-	dft, err = parseExpression(fl.tag.dft, isNumber)
+	dft, err := fl.schema.defaultValue(fl.app.getenv)
 	if err != nil {
 		return err
 	}
-	if isNumber && !isDecoder {
-		v, err := expr.Eval(dft, nil, nil)
-		if err == nil {
-			if fl.isInteger() {
-				dft = fmt.Sprintf("%d", v.Int())
-			} else if fl.isFloat() {
-				dft = fmt.Sprintf("%f", v.Float())
-			}
-		}
-	}
-	if !dontSetValue && fl.tag.dft != "" && dft != "" {
-		if fl.isPtr() || isDecoder || isEmpty(fl.value) {
+	if dft != "" {
+		if fl.isPtr() || fl.schema.isDecoder || isEmpty(fl.value) {
 			return fl.setDefault(dft, clr)
 		}
 	}
 	return nil
 }
 
+// promptTimeout returns timeout of prompting the flag, which is base if not
+// specified by tag
+func (fl *flag) promptTimeout(base time.Duration) time.Duration {
+	if fl.tag.promptTimeout > 0 {
+		return fl.tag.promptTimeout
+	}
+	return base
+}
+
+// editValue returns value to prefill editor, which is current value of a
+// string or default value of others
+func (fl *flag) editValue() (string, error) {
+	if fl.isString() {
+		return fl.value.String(), nil
+	}
+	if fl.tag.dft == "" {
+		return "", nil
+	}
+	return fl.schema.defaultValue(fl.app.getenv)
+}
+
 func isEmpty(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -123,6 +134,10 @@ const (
 )
 
 func parseExpression(s string, isNumber bool) (string, error) {
+	return parseExpressionWithEnv(s, isNumber, os.Getenv)
+}
+
+func parseExpressionWithEnv(s string, isNumber bool, getenv func(string) string) (string, error) {
 	const escapeByte = '$'
 	var (
 		src       = []byte(s)
@@ -153,7 +168,7 @@ func parseExpression(s string, isNumber bool) (string, error) {
 				value = filename
 			}
 		default:
-			value = os.Getenv(envName)
+			value = getenv(envName)
 			if value == "" && isNumber {
 				value = "0"
 			}
@@ -216,7 +231,11 @@ func (fl *flag) isBoolean() bool {
 }
 
 func (fl *flag) isInteger() bool {
-	switch fl.field.Type.Kind() {
+	return isIntegerKind(fl.field.Type.Kind())
+}
+
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
 	case reflect.Int,
 		reflect.Int8,
 		reflect.Int16,
@@ -241,7 +260,10 @@ func (fl *flag) isMap() bool {
 }
 
 func (fl *flag) isFloat() bool {
-	kind := fl.field.Type.Kind()
+	return isFloatKind(fl.field.Type.Kind())
+}
+
+func isFloatKind(kind reflect.Kind) bool {
 	return kind == reflect.Float32 || kind == reflect.Float64
 }
 
@@ -261,6 +283,9 @@ func (fl *flag) getBool() bool {
 }
 
 func (fl *flag) setDefault(s string, clr color.Color) error {
+	if err := fl.checkChoice(s, clr); err != nil {
+		return fmt.Errorf("default value of %s: %v", clr.Bold(fl.name()), err)
+	}
 	fl.isAssigned = true
 	if fl.isNeedDelaySet {
 		fl.lastValue = s
@@ -269,7 +294,23 @@ func (fl *flag) setDefault(s string, clr color.Color) error {
 	return setWithProperType(fl, fl.field.Type, fl.value, s, clr, false)
 }
 
+// checkChoice checks whether s is one of choices if any
+func (fl *flag) checkChoice(s string, clr color.Color) error {
+	if len(fl.tag.choices) == 0 || fl.field.Type.Kind() == reflect.Map {
+		return nil
+	}
+	for _, choice := range fl.tag.choices {
+		if s == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("`%s' isn't one of %s", s, clr.Bold(strings.Join(fl.tag.choices, ", ")))
+}
+
 func (fl *flag) set(actualFlagName, s string, clr color.Color) error {
+	if err := fl.checkChoice(s, clr); err != nil {
+		return err
+	}
 	fl.isSet = true
 	fl.isAssigned = true
 	fl.actualFlagName = actualFlagName
@@ -285,15 +326,13 @@ func (fl *flag) counterIncr(s string, clr color.Color) error {
 }
 
 func (fl *flag) isCounter() bool {
-	if decoder := tryGetDecoder(fl.value.Type().Kind(), fl.value); decoder != nil {
-		if _, ok := decoder.(CounterDecoder); ok {
-			return true
-		}
-	}
-	return false
+	return fl.schema.isCounter
 }
 
 func (fl *flag) setWithNoDelay(actualFlagName, s string, clr color.Color) error {
+	if err := fl.checkChoice(s, clr); err != nil {
+		return err
+	}
 	fl.isSet = true
 	fl.isAssigned = true
 	fl.actualFlagName = actualFlagName
@@ -322,11 +361,11 @@ func setWithProperType(fl *flag, typ reflect.Type, val reflect.Value, s string,
 	kind := typ.Kind()
 
 	// try parser first of all
-	if fl.tag.parserCreator != nil && val.CanInterface() {
+	if fl.parserCreator != nil && val.CanInterface() {
 		if kind != reflect.Ptr && val.CanAddr() {
 			val = val.Addr()
 		}
-		return fl.tag.parserCreator(val.Interface()).Parse(s)
+		return fl.parserCreator(val.Interface()).Parse(s)
 	}
 
 	if decoder := tryGetDecoder(kind, val); decoder != nil {
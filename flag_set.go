@@ -3,17 +3,20 @@ package cli
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"net/url"
+	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/labstack/gommon/color"
 )
 
 type flagSet struct {
-	err    error
-	values url.Values
-	args   []string
+	app     *App
+	console *console
+	err     error
+	values  url.Values
+	args    []string
 
 	flagMap   map[string]*flag
 	flagSlice []*flag
@@ -30,34 +33,54 @@ func newFlagSet() *flagSet {
 	}
 }
 
-func (fs *flagSet) readPrompt(w io.Writer, clr color.Color) {
+func (fs *flagSet) readPrompt(clr color.Color) {
+	base := fs.console.timeout
+	defer func() { fs.console.timeout = base }()
 	for _, fl := range fs.flagSlice {
 		if fl.isAssigned || fl.tag.prompt == "" {
 			continue
 		}
+		if !fs.console.canPrompt() {
+			fl.isInputUnavailable = true
+			continue
+		}
+		fs.console.timeout = fl.promptTimeout(base)
 		// read ...
 		prefix := fl.tag.prompt + ": "
 		var (
 			data string
 			yes  bool
+			list []string
 		)
 		if fl.tag.isPassword {
-			data, fs.err = password(prefix)
+			data, fs.err = fs.console.password(prefix)
 			if fs.err == nil && data != "" {
 				fl.setWithNoDelay("", data, clr)
 			}
 		} else if fl.isBoolean() {
-			yes, fs.err = ask(prefix, false)
+			yes, fs.err = fs.console.confirm(fl.tag.prompt, fl.getBool())
 			if fs.err == nil {
 				fl.setWithNoDelay("", fmt.Sprintf("%v", yes), clr)
 			}
+		} else if len(fl.tag.choices) > 0 && fl.field.Type.Kind() == reflect.Slice {
+			list, fs.err = fs.console.selectMany(fl.tag.prompt, fl.tag.choices)
+			for _, data := range list {
+				if fs.err == nil {
+					fs.err = fl.setWithNoDelay("", data, clr)
+				}
+			}
+		} else if len(fl.tag.choices) > 0 {
+			data, fs.err = fs.console.selectOne(fl.tag.prompt, fl.tag.choices, fl.tag.dft)
+			if fs.err == nil {
+				fs.err = fl.setWithNoDelay("", data, clr)
+			}
 		} else if fl.tag.dft != "" {
-			data, fs.err = promptDefault(prefix, fl.tag.dft)
+			data, fs.err = fs.console.promptDefault(prefix, fl.tag.dft)
 			if fs.err == nil {
 				fl.setWithNoDelay("", data, clr)
 			}
 		} else {
-			data, fs.err = prompt(prefix, fl.tag.isRequired)
+			data, fs.err = fs.console.prompt(prefix, fl.tag.isRequired)
 			if fs.err == nil {
 				fl.setWithNoDelay("", data, clr)
 			}
@@ -68,25 +91,156 @@ func (fs *flagSet) readPrompt(w io.Writer, clr color.Color) {
 	}
 }
 
+// readWizard asks for every flag not given in command line, required flags
+// first. The question is prompt or usage of flag, and the widget depends on
+// type of flag: confirm for boolean, select for choices, hidden input for
+// password and plain input for others. Edit flags are left to editor.
+func (fs *flagSet) readWizard(clr color.Color) {
+	flags := make([]*flag, 0, len(fs.flagSlice))
+	for _, fl := range fs.flagSlice {
+		if fl.isSet || fl.tag.isForce || fl.tag.isEdit || fl.isCounter() {
+			continue
+		}
+		flags = append(flags, fl)
+	}
+	sort.SliceStable(flags, func(i, j int) bool {
+		return flags[i].tag.isRequired && !flags[j].tag.isRequired
+	})
+	base := fs.console.timeout
+	defer func() { fs.console.timeout = base }()
+	for _, fl := range flags {
+		if !fs.console.canPrompt() {
+			fl.isInputUnavailable = fl.tag.isRequired
+			continue
+		}
+		fs.console.timeout = fl.promptTimeout(base)
+		if fs.err = fs.askFlag(fl, clr); fs.err != nil {
+			return
+		}
+	}
+}
+
+// askFlag reads value of fl in wizard
+func (fs *flagSet) askFlag(fl *flag, clr color.Color) error {
+	question := fl.tag.prompt
+	if question == "" {
+		question = fl.name()
+		if fl.tag.usage != "" {
+			question = fl.tag.usage + " (" + question + ")"
+		}
+	}
+	var dft string
+	if fl.tag.dft != "" {
+		s, err := fl.schema.defaultValue(fl.app.getenv)
+		if err != nil {
+			return err
+		}
+		dft = s
+	}
+	isSlice := fl.field.Type.Kind() == reflect.Slice && !fl.schema.isDecoder && fl.parserCreator == nil
+	switch {
+	case fl.isBoolean():
+		yes, err := fs.console.confirm(question, fl.getBool())
+		if err != nil {
+			return err
+		}
+		return fl.setWithNoDelay("", fmt.Sprintf("%v", yes), clr)
+	case len(fl.tag.choices) > 0 && isSlice:
+		list, err := fs.console.selectMany(question, fl.tag.choices)
+		if err != nil || len(list) == 0 {
+			return err
+		}
+		fl.value.Set(reflect.Zero(fl.field.Type))
+		for _, s := range list {
+			if err := fl.setWithNoDelay("", s, clr); err != nil {
+				return err
+			}
+		}
+		return nil
+	case len(fl.tag.choices) > 0:
+		s, err := fs.console.selectOne(question, fl.tag.choices, dft)
+		if err != nil {
+			return err
+		}
+		return fl.setWithNoDelay("", s, clr)
+	case fl.tag.isPassword:
+		for {
+			s, err := fs.console.password(question + ": ")
+			if err != nil {
+				return err
+			}
+			if s == "" && (fl.isAssigned || !fl.tag.isRequired) {
+				return nil
+			}
+			if s != "" {
+				return fl.setWithNoDelay("", s, clr)
+			}
+			fs.console.retry(errRequiredMissing)
+		}
+	}
+	// values of slice are separated by commas
+	split := func(s string) []string {
+		if !isSlice {
+			return []string{s}
+		}
+		return strings.Split(s, ",")
+	}
+	_, err := fs.console.inputDefault(question, dft, func(s string) error {
+		if s == "" {
+			if fl.isAssigned || !fl.tag.isRequired {
+				return nil
+			}
+			return errRequiredMissing
+		}
+		if isSlice {
+			fl.value.Set(reflect.Zero(fl.field.Type))
+		}
+		for _, v := range split(s) {
+			if err := fl.setWithNoDelay("", strings.TrimSpace(v), clr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// readEditor edits every edit flag not given by command line or prompts
+// like `git commit`: the file is prefilled with current or default value,
+// comments are stripped and an empty content aborts.
 func (fs *flagSet) readEditor(clr color.Color) {
-	editor, editorErr := getEditor()
+	editor, editorErr := fs.app.editor()
 	for _, fl := range fs.flagSlice {
-		if fl.isAssigned || !fl.tag.isEdit {
+		if fl.isSet || !fl.tag.isEdit {
+			continue
+		}
+		if !fs.console.canEdit(fs.app.LaunchEditor != nil) {
+			fl.isInputUnavailable = true
 			continue
 		}
 		if editorErr != nil {
 			fs.err = editorErr
 			return
 		}
-		filename := fl.tag.editFile
-		if filename == "" {
-			filename = randomFilename()
+		value, err := fl.editValue()
+		if fs.err = err; err != nil {
+			return
+		}
+		what := fl.tag.usage
+		if what == "" {
+			what = fl.name()
 		}
-		data, err := launchEditorWithFilename(editor, filename)
+		syntax := commentSyntaxOf(fl.tag.editFile)
+		data, err := editFile(fs.app, editor, fl.tag.editFile, []byte(editTemplate(value, what, syntax)), fs.console)
 		if fs.err = err; err != nil {
 			return
 		}
-		if fs.err = fl.setWithNoDelay("", string(data), clr); fs.err != nil {
+		content := stripComments(string(data), syntax)
+		if content == "" {
+			fs.err = fmt.Errorf("aborting due to empty %s", clr.Bold(fl.name()))
+			return
+		}
+		if fs.err = fl.setWithNoDelay("", content, clr); fs.err != nil {
 			return
 		}
 	}
@@ -106,21 +260,26 @@ const (
 	DenseManualStyle
 )
 
-var defaultStyle = NormalStyle
-
-// GetUsageStyle gets default style
+// GetUsageStyle gets style of default app
 func GetUsageStyle() UsageStyle {
-	return defaultStyle
+	return defaultApp.UsageStyle()
 }
 
-// SetUsageStyle sets default style
+// SetUsageStyle sets style of default app
 func SetUsageStyle(style UsageStyle) {
-	defaultStyle = style
+	defaultApp.SetUsageStyle(style)
 }
 
 type flagSlice []*flag
 
-func (fs flagSlice) String(clr color.Color) string {
+func (fs flagSlice) String(clr color.Color, style UsageStyle) string {
+	return fs.rowsString(fs, clr, style, 0)
+}
+
+// rowsString returns usage of rows in style NormalStyle or DenseNormalStyle,
+// which are aligned with all flags of fs. Usage text is wrapped to width
+// under the column of usage if width isn't 0.
+func (fs flagSlice) rowsString(rows flagSlice, clr color.Color, style UsageStyle, width int) string {
 	var (
 		lenShort                 = 0
 		lenLong                  = 0
@@ -132,38 +291,39 @@ func (fs flagSlice) String(clr color.Color) string {
 		tag := fl.tag
 		l := 0
 		for _, shortName := range tag.shortNames {
-			l += len(shortName) + lenSep
+			l += displayWidth(shortName) + lenSep
 		}
 		if l > lenShort {
 			lenShort = l
 		}
 		l = 0
 		for _, longName := range tag.longNames {
-			l += len(longName) + lenSep
+			l += displayWidth(longName) + lenSep
 		}
 		if l > lenLong {
 			lenLong = l
 		}
 		lenDft := 0
-		if defaultStyle == NormalStyle && tag.dft != "" {
-			lenDft = len(tag.dft) + 3 // 3=len("[=]")
+		if style == NormalStyle && tag.dft != "" {
+			lenDft = displayWidth(tag.dft) + 3 // 3=len("[=]")
 			l += lenDft
 		}
 		if tag.name != "" {
-			l += len(tag.name) + 1 // 1=len("=")
+			l += displayWidth(tag.name) + 1 // 1=len("=")
 		}
 		if l > lenNameAndDefaultAndLong {
 			lenNameAndDefaultAndLong = l
 		}
 	}
+	// column of usage text, which is after the prefix `*` or space
+	usageColumn := lenShort + lenSep + lenNameAndDefaultAndLong + 1
 
 	buff := bytes.NewBufferString("")
-	for _, fl := range fs {
+	for _, fl := range rows {
 		var (
 			tag         = fl.tag
 			shortStr    = strings.Join(tag.shortNames, sepName)
 			longStr     = strings.Join(tag.longNames, sepName)
-			format      = ""
 			defaultStr  = ""
 			nameStr     = ""
 			usagePrefix = " "
@@ -172,7 +332,7 @@ func (fs flagSlice) String(clr color.Color) string {
 
 		if tag.dft != "" {
 			defaultStr = fmt.Sprintf("[=%s]", tag.dft)
-			lenDft = len(defaultStr)
+			lenDft = displayWidth(defaultStr)
 			defaultStr = clr.Grey(defaultStr)
 		}
 		if tag.name != "" {
@@ -181,7 +341,7 @@ func (fs flagSlice) String(clr color.Color) string {
 		if tag.isRequired {
 			usagePrefix = clr.Red("*")
 		}
-		usage := usagePrefix + tag.usage
+		usage := tag.usage
 		lastNotNewLineIndex := len(usage) - 1
 		for i := len(usage) - 1; i >= 0; i-- {
 			if usage[i] != '\n' {
@@ -191,30 +351,30 @@ func (fs flagSlice) String(clr color.Color) string {
 		}
 
 		// move defaultStr to the end when in DenseNormalStyle
-		if defaultStyle == DenseNormalStyle {
+		if style == DenseNormalStyle {
 			usage = usage[:lastNotNewLineIndex+1] + " " + defaultStr + usage[lastNotNewLineIndex+1:]
 			defaultStr = ""
 			lenDft = 0
 		}
+		usage = usagePrefix + wrapIndent(usage, width, usageColumn)
 
-		spaceSize -= len(nameStr) + lenDft + len(longStr)
+		spaceSize -= displayWidth(nameStr) + lenDft + displayWidth(longStr)
 
 		if nameStr != "" {
 			nameStr = "=" + clr.Bold(tag.name)
 		}
 
 		if longStr == "" {
-			format = fmt.Sprintf("%%%ds%%s%s%%s", lenShort, sepSpaces)
 			fillStr := fillSpaces(nameStr+defaultStr, spaceSize)
-			fmt.Fprintf(buff, format+"\n", shortStr, fillStr, usage)
+			fmt.Fprintf(buff, "%s%s%s%s\n", padLeft(shortStr, lenShort), fillStr, sepSpaces, usage)
 		} else {
 			if shortStr == "" {
-				format = fmt.Sprintf("%%%ds%%s%%s", lenShort+lenSep)
+				shortStr = padLeft("", lenShort+lenSep)
 			} else {
-				format = fmt.Sprintf("%%%ds%s%%s%%s", lenShort, sepName)
+				shortStr = padLeft(shortStr, lenShort) + sepName
 			}
 			fillStr := fillSpaces(longStr+nameStr+defaultStr, spaceSize)
-			fmt.Fprintf(buff, format+"\n", shortStr, fillStr, usage)
+			fmt.Fprintf(buff, "%s%s%s\n", shortStr, fillStr, usage)
 		}
 	}
 	return buff.String()
@@ -225,17 +385,23 @@ func fillSpaces(s string, spaceSize int) string {
 }
 
 func (fs flagSlice) StringWithStyle(clr color.Color, style UsageStyle) string {
+	return fs.sectionString(fs, clr, style, 0)
+}
+
+// sectionString returns usage of rows in style, which are aligned with all
+// flags of fs. Usage text is wrapped to width if width isn't 0.
+func (fs flagSlice) sectionString(rows flagSlice, clr color.Color, style UsageStyle, width int) string {
 	if style != ManualStyle && style != DenseManualStyle {
-		return fs.String(clr)
+		return fs.rowsString(rows, clr, style, width)
 	}
 
 	buf := bytes.NewBufferString("")
 	linePrefix := "  "
-	for i, fl := range fs {
+	for i, fl := range rows {
 		if i != 0 {
 			buf.WriteString("\n")
 		}
-		names := strings.Join(append(fl.tag.shortNames, fl.tag.longNames...), sepName)
+		names := strings.Join(fl.tag.names(), sepName)
 		buf.WriteString(linePrefix)
 		buf.WriteString(clr.Bold(names))
 		if fl.tag.name != "" {
@@ -247,13 +413,43 @@ func (fs flagSlice) StringWithStyle(clr color.Color, style UsageStyle) string {
 		buf.WriteString("\n")
 		buf.WriteString(linePrefix)
 		buf.WriteString("    ")
+		indent := len(linePrefix) + 4
 		if fl.tag.isRequired {
 			buf.WriteString(clr.Red("*"))
+			indent++
 		}
-		buf.WriteString(fl.tag.usage)
+		buf.WriteString(wrapIndent(fl.tag.usage, width, indent))
 		if style != DenseManualStyle {
 			buf.WriteString("\n")
 		}
 	}
 	return buf.String()
 }
+
+// flagGroup is a section of flags in usage
+type flagGroup struct {
+	name  string
+	flags flagSlice
+}
+
+// groups splits flags by tag `group`, ungrouped flags come first and then
+// groups in order of declaration
+func (fs flagSlice) groups() []flagGroup {
+	var (
+		groups  = []flagGroup{{}}
+		indexes = map[string]int{"": 0}
+	)
+	for _, fl := range fs {
+		i, ok := indexes[fl.tag.group]
+		if !ok {
+			i = len(groups)
+			indexes[fl.tag.group] = i
+			groups = append(groups, flagGroup{name: fl.tag.group})
+		}
+		groups[i].flags = append(groups[i].flags, fl)
+	}
+	if len(groups[0].flags) == 0 {
+		groups = groups[1:]
+	}
+	return groups
+}
@@ -10,4 +10,5 @@ require (
 	github.com/mkideal/pkg v0.1.3
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/crypto v0.1.0
+	golang.org/x/sys v0.1.0
 )
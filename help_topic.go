@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Example is an example of running a command, which is shown in usage,
+	// man pages and schema of the command
+	Example struct {
+		Cmd  string `json:"cmd"`            // command line, e.g. "app deploy --env=prod"
+		Desc string `json:"desc,omitempty"` // what the example does
+	}
+
+	// HelpTopic is a non-executable entry of help, which is shown by the
+	// builtin help command, e.g.
+	//
+	//	app help environment
+	HelpTopic struct {
+		Name string `json:"name"`
+		Desc string `json:"desc,omitempty"`
+		Text string `json:"text,omitempty"`
+	}
+)
+
+// RegisterTopic registers a help topic of the command, which is listed in
+// usage of the command and routed by HelpCommandFn
+func (cmd *Command) RegisterTopic(topic *HelpTopic) *HelpTopic {
+	if topic == nil {
+		panic("command `" + cmd.Name + "` try register a nil help topic")
+	}
+	if !IsValidCommandName(topic.Name) {
+		panic("illegal help topic name `" + topic.Name + "`")
+	}
+	if cmd.findChild(topic.Name) != nil || cmd.findTopic(topic.Name) != nil {
+		panic("repeat register help topic `" + topic.Name + "` for command `" + cmd.Name + "`")
+	}
+	cmd.topics = append(cmd.topics, topic)
+	return topic
+}
+
+// Topics returns help topics registered to the command
+func (cmd *Command) Topics() []*HelpTopic {
+	return cmd.topics
+}
+
+func (cmd *Command) findTopic(name string) *HelpTopic {
+	for _, topic := range cmd.topics {
+		if topic.Name == name {
+			return topic
+		}
+	}
+	return nil
+}
+
+// routeTopic finds the help topic by router, whose last word is name of
+// the topic and leading words route to the command owning it
+func (cmd *Command) routeTopic(router []string) *HelpTopic {
+	if len(router) == 0 {
+		return nil
+	}
+	owner := cmd.Route(router[:len(router)-1])
+	if owner == nil {
+		return nil
+	}
+	return owner.findTopic(router[len(router)-1])
+}
+
+// String returns text shown by the help command
+func (topic *HelpTopic) String() string {
+	buff := bytes.NewBufferString("")
+	if topic.Desc != "" {
+		fmt.Fprintf(buff, "%s\n", topic.Desc)
+	}
+	if text := strings.TrimRight(topic.Text, "\n"); text != "" {
+		if topic.Desc != "" {
+			buff.WriteByte('\n')
+		}
+		fmt.Fprintf(buff, "%s\n", text)
+	}
+	return buff.String()
+}
+
+// examplesString returns examples in usage, each description is shown as a
+// comment above the command line
+func examplesString(examples []Example, prefix string, width int) string {
+	buff := bytes.NewBufferString("")
+	for i, example := range examples {
+		if i > 0 {
+			buff.WriteByte('\n')
+		}
+		if example.Desc != "" {
+			for _, line := range wrapText(example.Desc, width-displayWidth(prefix)-2) {
+				fmt.Fprintf(buff, "%s# %s\n", prefix, line)
+			}
+		}
+		fmt.Fprintf(buff, "%s%s\n", prefix, example.Cmd)
+	}
+	return buff.String()
+}
+
+// topicsDescriptions returns names and descriptions of help topics, which
+// are aligned like children
+func topicsDescriptions(topics []*HelpTopic, prefix, indent string, width int) string {
+	buff := bytes.NewBufferString("")
+	length := 0
+	for _, topic := range topics {
+		if l := displayWidth(topic.Name); l > length {
+			length = l
+		}
+	}
+	column := displayWidth(prefix) + length + displayWidth(indent)
+	for _, topic := range topics {
+		desc := wrapIndent(topic.Desc, width, column)
+		fmt.Fprintf(buff, "%s%s%s%s\n", prefix, padRight(topic.Name, length), indent, desc)
+	}
+	return buff.String()
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExamplesAndTopics(t *testing.T) {
+	root := &Command{Name: "app"}
+	root.Register(HelpCommand("show help"))
+	deploy := root.Register(&Command{
+		Name: "deploy",
+		Desc: "deploy the service",
+		Fn:   donothing,
+		Examples: []Example{
+			{Cmd: "app deploy", Desc: "deploy to staging"},
+			{Cmd: "app deploy prod"},
+		},
+	})
+	root.RegisterTopic(&HelpTopic{Name: "environment", Desc: "Environment variables", Text: "APP_HOME  home directory\n"})
+	deploy.RegisterTopic(&HelpTopic{Name: "targets", Desc: "Deploy targets"})
+
+	assert.Panics(t, func() { root.RegisterTopic(&HelpTopic{Name: "deploy"}) })
+	assert.Panics(t, func() { root.Register(&Command{Name: "environment"}) })
+
+	app := NewApp(root)
+	assert.Equal(t, `deploy the service
+
+Usage: app deploy
+
+Examples:
+
+  # deploy to staging
+  app deploy
+
+  app deploy prod
+
+Help topics:
+
+  targets   Deploy targets
+`, app.Usage(deploy))
+	assert.Contains(t, app.Usage(root), "Help topics:\n\n  environment   Environment variables\n")
+
+	for want, args := range map[string][]string{
+		"Environment variables\n\nAPP_HOME  home directory\n": {"help", "environment"},
+		"Deploy targets\n": {"help", "deploy", "targets"},
+	} {
+		w := bytes.NewBufferString("")
+		assert.Nil(t, root.RunWith(args, w, nil))
+		assert.Equal(t, want, w.String())
+	}
+	assert.Error(t, root.RunWith([]string{"help", "deploy", "environment"}, nil, nil))
+
+	s := deploy.Schema()
+	assert.Equal(t, deploy.Examples, s.Examples)
+	assert.Equal(t, "targets", s.Topics[0].Name)
+
+	var man, markdown bytes.Buffer
+	assert.Nil(t, GenMan(deploy, &man, nil))
+	assert.Contains(t, man.String(), ".SH EXAMPLES\n.PP\ndeploy to staging\n.PP\n.RS 4\n.nf\napp deploy\n.fi\n.RE\n")
+	assert.Nil(t, GenMarkdown(deploy, &markdown))
+	assert.Contains(t, markdown.String(), "## Examples\n\ndeploy to staging\n\n```\napp deploy\n```\n\n```\napp deploy prod\n```\n")
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -43,6 +45,10 @@ func (cmd *Command) RegisterHTTP(ctxs ...*Context) error {
 
 // ServeHTTP implements HTTP handler
 func (cmd *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cmd.serveHTTP(defaultApp, w, r)
+}
+
+func (cmd *Command) serveHTTP(app *App, w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		return
 	}
@@ -79,24 +85,28 @@ func (cmd *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	buf := new(bytes.Buffer)
-	statusCode := http.StatusOK
-	if err := cmd.RunWith(args, buf, w, r.Method); err != nil {
-		buf.Write([]byte(err.Error()))
-		nativeError := err
-		if werr, ok := err.(wrapError); ok {
-			nativeError = werr.err
-		}
-		switch nativeError.(type) {
-		case commandNotFoundError:
-			statusCode = http.StatusNotFound
-
-		case methodNotAllowedError:
-			statusCode = http.StatusMethodNotAllowed
-
-		default:
-			statusCode = http.StatusInternalServerError
+	err := cmd.run(&runOptions{
+		app:         app,
+		ctx:         r.Context(),
+		req:         r,
+		stdin:       strings.NewReader(""),
+		stdout:      buf,
+		resp:        w,
+		httpMethods: []string{r.Method},
+		noInput:     true,
+	}, args)
+	if err != nil {
+		var pe *PanicError
+		if errors.As(err, &pe) {
+			// never leak the panic value to clients
+			fmt.Fprintln(app.stderr(), err)
+			buf.WriteString(http.StatusText(http.StatusInternalServerError))
+		} else {
+			buf.WriteString(err.Error())
 		}
+		app.printStack(app.stderr(), err)
 	}
+	statusCode := httpStatusOf(err)
 	w.WriteHeader(statusCode)
 	w.Write(buf.Bytes())
 }
@@ -107,17 +117,21 @@ func (cmd *Command) ListenAndServeHTTP(addr string) error {
 	return http.ListenAndServe(addr, cmd)
 }
 
-// Serve set IsServer with true and serve http with listeners
+// Serve set IsServer with true and serve http with listeners,
+// it returns after all listeners closed with the first error
 func (cmd *Command) Serve(listeners ...net.Listener) (err error) {
 	cmd.SetIsServer(true)
-	var g sync.WaitGroup
+	var (
+		g    sync.WaitGroup
+		once sync.Once
+	)
 	for _, ln := range listeners {
 		g.Add(1)
 		go func(ln net.Listener) {
+			defer g.Done()
 			if e := http.Serve(ln, cmd); e != nil {
-				panic(e.Error())
+				once.Do(func() { err = e })
 			}
-			g.Done()
 		}(ln)
 	}
 	g.Wait()
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeHTTPSharedArgv(t *testing.T) {
+	type argT struct {
+		Name string   `cli:"name"`
+		Tags []string `cli:"tag"`
+	}
+	shared := new(argT)
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return shared },
+		Fn: func(ctx *Context) error {
+			argv := ctx.Argv().(*argT)
+			ctx.String("%s:%v", argv.Name, argv.Tags)
+			return nil
+		},
+	}
+	root.SetIsServer(true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := fmt.Sprintf("/?name=n%d&tag=t%d", i, i)
+			w := httptest.NewRecorder()
+			root.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, fmt.Sprintf("n%d:[t%d]", i, i), w.Body.String())
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, "", shared.Name)
+}
+
+func TestServeHTTPContext(t *testing.T) {
+	root := &Command{
+		Name: "root",
+		Fn: func(ctx *Context) error {
+			assert.NotNil(t, ctx.HTTPRequest)
+			<-ctx.Context().Done()
+			return ctx.Context().Err()
+		},
+	}
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(parent)
+	w := httptest.NewRecorder()
+	root.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, context.Canceled.Error(), w.Body.String())
+}
+
+func TestServeHTTPPanic(t *testing.T) {
+	var handled error
+	root := &Command{
+		Name: "root",
+		Fn:   func(*Context) error { panic("boom") },
+		OnError: func(_ *Context, err error) error {
+			handled = err
+			return err
+		},
+	}
+	stderr := new(bytes.Buffer)
+	app := NewApp(root)
+	app.Stderr = stderr
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal Server Error", w.Body.String())
+	assert.NotContains(t, w.Body.String(), "boom")
+	assert.Contains(t, stderr.String(), "panic: boom")
+	assert.IsType(t, &PanicError{}, handled)
+
+	// Serve returns error instead of panicking
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	ln.Close()
+	assert.Error(t, root.Serve(ln))
+}
@@ -13,14 +13,10 @@ type FlagParser interface {
 // FlagParserCreator represents factory function of FlagParser
 type FlagParserCreator func(ptr interface{}) FlagParser
 
-var parserCreators = map[string]FlagParserCreator{}
-
-// RegisterFlagParser registers FlagParserCreator by name
+// RegisterFlagParser registers FlagParserCreator by name for default app,
+// parsers of default app are visible to all apps
 func RegisterFlagParser(name string, creator FlagParserCreator) {
-	if _, ok := parserCreators[name]; ok {
-		panic("RegisterFlagParser has registered: " + name)
-	}
-	parserCreators[name] = creator
+	defaultApp.RegisterFlagParser(name, creator)
 }
 
 func init() {
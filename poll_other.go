@@ -0,0 +1,17 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package cli
+
+import (
+	"context"
+	"os"
+)
+
+// canPoll reports whether a read of file could be cancelled by polling
+const canPoll = false
+
+// waitReadable returns at once, reads can't be cancelled
+func waitReadable(ctx context.Context, f *os.File) error {
+	return ctx.Err()
+}
@@ -1,27 +1,301 @@
 package cli
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 var (
 	errRequiredMissing = errors.New("required missing")
-	errInvalidBoolean  = errors.New("invalid boolean")
+	errInvalidBoolean  = errors.New("please answer yes or no")
+	errInvalidChoice   = errors.New("please enter a number or an option listed")
+	errPasswordNotSame = errors.New("passwords do not match")
+
+	// ErrInputUnavailable is returned by prompts if stdin isn't a terminal
+	// or interactive input is disabled
+	ErrInputUnavailable = errors.New("interactive input unavailable")
+	// ErrPromptTimeout is returned by prompts if no answer given in time
+	ErrPromptTimeout = errors.New("prompt timed out")
+	// ErrInterrupted is returned by terminal prompts if Ctrl-C or Ctrl-D
+	// pressed
+	ErrInterrupted = errors.New("interrupted")
+)
+
+const (
+	// InteractiveFlag is the global switch which asks for every flag not
+	// given in command line
+	InteractiveFlag = "--interactive"
+	// NoInputFlag is the global switch which disables all interactive input
+	NoInputFlag = "--no-input"
+	// NoInputEnv is the environment variable which disables all interactive
+	// input if it's true, e.g. NoInputEnv=1
+	NoInputEnv = "CLI_NO_INPUT"
 )
 
+// extractSwitch removes global switch name before `--` from args. The
+// switch is kept if the command routed by args declares a flag of the same
+// name, and values of flags of the command are never taken as the switch.
+func (cmd *Command) extractSwitch(args []string, name string) ([]string, bool) {
+	router := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, dashOne) {
+			break
+		}
+		router = append(router, arg)
+	}
+	child, _ := cmd.SubRoute(router)
+	if child.hasFlag(name) {
+		return args, false
+	}
+	flags := map[string]*flag{}
+	if fs, ok := usageFlags(child.argvList()); ok {
+		for _, fl := range fs {
+			for _, s := range fl.tag.names() {
+				flags[s] = fl
+			}
+		}
+	}
+
+	found := false
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == dashTwo {
+			out = append(out, args[i:]...)
+			break
+		}
+		if arg == name {
+			found = true
+			continue
+		}
+		out = append(out, arg)
+		if fl, ok := flags[arg]; ok && fl.needValue() && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return out, found
+}
+
+func isTrue(s string) bool {
+	b, err := strconv.ParseBool(s)
+	return err == nil && b
+}
+
 type readerWriter struct {
 	io.Reader
 	io.Writer
 }
 
-func doPrompt(text string, password bool) (string, error) {
-	stdin := os.Stdin
-	stdout := os.Stdout
-	term := terminal.NewTerminal(readerWriter{stdin, stdout}, text)
+// console holds standard streams of an invocation. Prompts are written to
+// stderr and answered from stdin, a terminal stdin is read in raw mode and
+// any other reader is read line by line.
+type console struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	reader *bufio.Reader
+
+	// noInput disables all interactive input
+	noInput bool
+	// allowPipe allows reading prompts from a stdin which isn't a terminal
+	allowPipe bool
+	// wizard asks for every flag not given in command line
+	wizard bool
+
+	// ctx cancels prompts
+	ctx context.Context
+	// timeout of prompts, no timeout if zero
+	timeout time.Duration
+	// pending is result of a read not returned before prompt done
+	pending chan promptResult
+	// file reads stdin if it's a file which could be polled
+	file *fileReader
+}
+
+// pollInterval is milliseconds waited by a poll before checking whether
+// the prompt is done
+const pollInterval = 20
+
+// fileReader reads a file after it's readable, and fails once ctx done so
+// that no read is left blocked in background after a prompt timed out or
+// cancelled. It's a plain reader of file if ctx is nil.
+type fileReader struct {
+	file *os.File
+	ctx  context.Context
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.ctx != nil {
+		if err := waitReadable(r.ctx, r.file); err != nil {
+			return 0, err
+		}
+	}
+	return r.file.Read(p)
+}
+
+// fileReader returns reader of stdin which could be cancelled by ctx, nil
+// returned if stdin isn't a file or couldn't be polled
+func (c *console) fileReader() *fileReader {
+	if c.file == nil && canPoll {
+		if f, ok := c.stdin.(*os.File); ok {
+			c.file = &fileReader{file: f}
+		}
+	}
+	return c.file
+}
+
+func newConsole(stdin io.Reader, stdout, stderr io.Writer) *console {
+	return &console{stdin: stdin, stdout: stdout, stderr: stderr}
+}
+
+// input returns reader of stdin, which contains data buffered by prompts
+func (c *console) input() io.Reader {
+	if c.reader != nil {
+		return c.reader
+	}
+	return c.stdin
+}
+
+func (c *console) terminalFile() (*os.File, bool) {
+	f, ok := c.stdin.(*os.File)
+	return f, ok && terminal.IsTerminal(int(f.Fd()))
+}
+
+// canPrompt reports whether prompts could be answered. A terminal or an
+// injected reader is always readable, while a pipe or a regular file is
+// read only if allowed.
+func (c *console) canPrompt() bool {
+	if c.noInput {
+		return false
+	}
+	if f, ok := c.stdin.(*os.File); ok && !terminal.IsTerminal(int(f.Fd())) {
+		return c.allowPipe
+	}
+	return true
+}
+
+// canEdit reports whether an editor could be launched, which needs a
+// terminal unless the editor is launched by app
+func (c *console) canEdit(hasLauncher bool) bool {
+	if c.noInput {
+		return false
+	}
+	if hasLauncher {
+		return true
+	}
+	_, ok := c.terminalFile()
+	return ok
+}
+
+func (c *console) doPrompt(text string, password bool) (string, error) {
+	if !c.canPrompt() {
+		return "", ErrInputUnavailable
+	}
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx := parent
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, c.timeout)
+		defer cancel()
+	}
+	var (
+		line string
+		err  error
+	)
+	if f, ok := c.terminalFile(); ok {
+		line, err = c.doTerminalPrompt(ctx, f, text, password)
+	} else {
+		fmt.Fprint(c.stderr, text)
+		fr := c.fileReader()
+		if c.reader == nil {
+			if fr != nil {
+				c.reader = bufio.NewReader(fr)
+			} else {
+				c.reader = bufio.NewReader(c.stdin)
+			}
+		}
+		read := func() (string, error) {
+			line, err := c.reader.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				return "", err
+			}
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+		if fr != nil {
+			fr.ctx = ctx
+			line, err = read()
+			fr.ctx = nil
+		} else {
+			line, err = c.await(ctx, read)
+		}
+		if password && err == nil {
+			fmt.Fprintln(c.stderr)
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		fmt.Fprintln(c.stderr)
+		if parent.Err() == nil {
+			return "", ErrPromptTimeout
+		}
+		return "", parent.Err()
+	}
+	return line, err
+}
+
+// promptResult is result of a background read
+type promptResult struct {
+	line string
+	err  error
+}
+
+// await calls read, which runs in background if ctx could be done. A read
+// not returned before ctx done is kept pending, and its result is taken by
+// the next prompt, so that stdin is never read concurrently. It's used only
+// if stdin couldn't be polled, see fileReader.
+func (c *console) await(ctx context.Context, read func() (string, error)) (string, error) {
+	if c.pending == nil {
+		if ctx.Done() == nil {
+			return read()
+		}
+		ch := make(chan promptResult, 1)
+		go func() {
+			line, err := read()
+			ch <- promptResult{line, err}
+		}()
+		c.pending = ch
+	}
+	select {
+	case r := <-c.pending:
+		c.pending = nil
+		return r.line, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// doTerminalPrompt reads a line in raw mode, the terminal state is restored
+// whether the line is read, interrupted by Ctrl-C, timed out or cancelled.
+// The read stops with the prompt if the terminal could be polled, so that
+// it never takes input after the prompt.
+func (c *console) doTerminalPrompt(ctx context.Context, stdin *os.File, text string, password bool) (string, error) {
+	var input io.Reader = stdin
+	if canPoll {
+		input = &fileReader{file: stdin, ctx: ctx}
+	}
+	term := terminal.NewTerminal(readerWriter{input, c.stderr}, text)
 	stdinFD := int(stdin.Fd())
 	stdinState, err := terminal.MakeRaw(stdinFD)
 	if err != nil {
@@ -29,20 +303,28 @@ func doPrompt(text string, password bool) (string, error) {
 	}
 	defer terminal.Restore(stdinFD, stdinState)
 
+	read := func() (string, error) {
+		if password {
+			return term.ReadPassword(text)
+		}
+		return term.ReadLine()
+	}
 	var line string
-	if password {
-		line, err = term.ReadPassword(text)
+	if canPoll {
+		line, err = read()
 	} else {
-		line, err = term.ReadLine()
+		line, err = c.await(ctx, read)
 	}
-	if err != nil {
-		return "", err
+	if err == io.EOF {
+		// Ctrl-C and Ctrl-D
+		fmt.Fprint(c.stderr, "\r\n")
+		return "", ErrInterrupted
 	}
-	return line, nil
+	return line, err
 }
 
-func prompt(text string, required bool) (string, error) {
-	line, err := doPrompt(text, false)
+func (c *console) prompt(text string, required bool) (string, error) {
+	line, err := c.doPrompt(text, false)
 	if err != nil {
 		return line, err
 	}
@@ -52,8 +334,8 @@ func prompt(text string, required bool) (string, error) {
 	return line, err
 }
 
-func promptDefault(text string, dft string) (string, error) {
-	line, err := doPrompt(text, false)
+func (c *console) promptDefault(text string, dft string) (string, error) {
+	line, err := c.doPrompt(text, false)
 	if err != nil {
 		return line, err
 	}
@@ -63,17 +345,167 @@ func promptDefault(text string, dft string) (string, error) {
 	return line, err
 }
 
-func password(text string) (string, error) {
-	return doPrompt(text, true)
+func (c *console) password(text string) (string, error) {
+	return c.doPrompt(text, true)
 }
 
-func ask(question string, dft bool) (bool, error) {
-	line, err := doPrompt(question, false)
-	if err != nil {
-		return false, err
+// retry reports why an answer is rejected before asking again
+func (c *console) retry(err error) {
+	fmt.Fprintln(c.stderr, err)
+}
+
+// confirm asks a yes/no question, the default answer is shown as [Y/n] or
+// [y/N] and used if answer is empty
+func (c *console) confirm(question string, dft bool) (bool, error) {
+	hint := "[y/N]"
+	if dft {
+		hint = "[Y/n]"
 	}
-	if line == "" {
-		return dft, nil
+	for {
+		line, err := c.doPrompt(question+" "+hint+": ", false)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return dft, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		c.retry(errInvalidBoolean)
+	}
+}
+
+// inputValid asks question until answer accepted by validate
+func (c *console) inputValid(question string, validate func(string) error) (string, error) {
+	for {
+		line, err := c.doPrompt(question+": ", false)
+		if err != nil {
+			return "", err
+		}
+		if validate == nil {
+			return line, nil
+		}
+		if err := validate(line); err != nil {
+			c.retry(err)
+			continue
+		}
+		return line, nil
+	}
+}
+
+// inputDefault is similar to inputValid, but dft is shown and accepted
+// if answer is empty
+func (c *console) inputDefault(question, dft string, validate func(string) error) (string, error) {
+	if dft == "" {
+		return c.inputValid(question, validate)
+	}
+	return c.inputValid(question+" ["+dft+"]", func(s string) error {
+		if s == "" || validate == nil {
+			return nil
+		}
+		return validate(s)
+	})
+}
+
+// passwordTwice reads a password, and reads it again if confirm is true
+// until both entries are the same
+func (c *console) passwordTwice(question string, confirm bool) (string, error) {
+	for {
+		pw, err := c.password(question + ": ")
+		if err != nil || !confirm {
+			return pw, err
+		}
+		again, err := c.password(question + " (again): ")
+		if err != nil {
+			return "", err
+		}
+		if pw == again {
+			return pw, nil
+		}
+		c.retry(errPasswordNotSame)
+	}
+}
+
+// listOptions writes question and numbered options
+func (c *console) listOptions(question string, options []string) {
+	fmt.Fprintf(c.stderr, "%s:\n", question)
+	for i, opt := range options {
+		fmt.Fprintf(c.stderr, "  %d) %s\n", i+1, opt)
+	}
+}
+
+// choice finds option by number(1-based) or by itself
+func choice(options []string, s string) (string, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n >= 1 && n <= len(options) {
+			return options[n-1], true
+		}
+		return "", false
+	}
+	for _, opt := range options {
+		if opt == s {
+			return opt, true
+		}
+	}
+	return "", false
+}
+
+// selectOne asks to choose one of options, dft used if answer is empty
+func (c *console) selectOne(question string, options []string, dft string) (string, error) {
+	c.listOptions(question, options)
+	text := fmt.Sprintf("Enter a number [1-%d]: ", len(options))
+	if dft != "" {
+		text = fmt.Sprintf("Enter a number [1-%d] (%s): ", len(options), dft)
+	}
+	for {
+		line, err := c.doPrompt(text, false)
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && dft != "" {
+			return dft, nil
+		}
+		if opt, ok := choice(options, line); ok {
+			return opt, nil
+		}
+		c.retry(errInvalidChoice)
+	}
+}
+
+// selectMany asks to choose any of options, answers are separated by
+// commas or spaces and an empty answer chooses nothing
+func (c *console) selectMany(question string, options []string) ([]string, error) {
+	c.listOptions(question, options)
+	text := fmt.Sprintf("Enter numbers separated by commas [1-%d]: ", len(options))
+	for {
+		line, err := c.doPrompt(text, false)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		chosen := make([]string, 0, len(fields))
+		seen := make(map[string]bool, len(fields))
+		valid := true
+		for _, field := range fields {
+			opt, ok := choice(options, field)
+			if !ok {
+				valid = false
+				break
+			}
+			if !seen[opt] {
+				seen[opt] = true
+				chosen = append(chosen, opt)
+			}
+		}
+		if valid {
+			return chosen, nil
+		}
+		c.retry(errInvalidChoice)
 	}
-	return line == "y" || line == "Y" || line == "yes" || line == "Yes" || line == "YES", nil
 }
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a pseudo terminal, the test is skipped if unavailable
+func openPty(t *testing.T) (master, slave *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("pty unavailable:", err)
+	}
+	fd := int(master.Fd())
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err == nil {
+		err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0)
+	}
+	if err == nil {
+		slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	}
+	if err != nil {
+		master.Close()
+		t.Skip("pty unavailable:", err)
+	}
+	return master, slave
+}
+
+func TestTerminalPromptTimeout(t *testing.T) {
+	master, slave := openPty(t)
+	defer master.Close()
+	defer slave.Close()
+	go func() {
+		// drain echo of the terminal
+		buf := make([]byte, 1024)
+		for {
+			if _, err := master.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := newConsole(slave, new(bytes.Buffer), new(bytes.Buffer))
+	c.timeout = 20 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		_, err := c.prompt("name: ", false)
+		assert.Equal(t, ErrPromptTimeout, err, "prompt %d", i)
+	}
+
+	// the next line is read by the command rather than prompts timed out
+	_, err := master.Write([]byte("next\n"))
+	require.Nil(t, err)
+	got := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := slave.Read(buf)
+		got <- string(buf[:n])
+	}()
+	select {
+	case line := <-got:
+		assert.Equal(t, "next\n", line)
+	case <-time.After(time.Second):
+		t.Fatal("the line is swallowed by a prompt timed out")
+	}
+}
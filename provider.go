@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider creates a dependency lazily on first use, e.g. a database
+// connection opened by flags. Close is called after OnAfter of the command,
+// even if the command fails.
+type Provider struct {
+	New   func(*Context) (interface{}, error)
+	Close func(interface{}) error
+}
+
+// resource is a dependency created by provider
+type resource struct {
+	value interface{}
+	close func(interface{}) error
+}
+
+// Provide registers provider of key, which is inherited by sub-commands and
+// overrides the one registered by ancestors
+func (cmd *Command) Provide(key interface{}, provider Provider) *Command {
+	if cmd.providers == nil {
+		cmd.providers = make(map[interface{}]Provider)
+	}
+	cmd.providers[key] = provider
+	return cmd
+}
+
+// findProvider finds provider of key in the command and it's ancestors
+func (cmd *Command) findProvider(key interface{}) (Provider, bool) {
+	for c := cmd; c != nil; c = c.parent {
+		if provider, ok := c.providers[key]; ok {
+			return provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// creation is a value being created by provider, which is waited by
+// concurrent Get of the key
+type creation struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// Get returns value set by Set, or creates it by provider registered with
+// Provide and sets it. The value is created once per invocation, providers
+// could get other values by the context passed to them, but not the ones
+// they are creating.
+func (ctx *Context) Get(key interface{}) (interface{}, error) {
+	for i, k := range ctx.creating {
+		if k == key {
+			return nil, fmt.Errorf("cycle in providers: %s", joinKeys(append(ctx.creating[i:], key)))
+		}
+	}
+	o := ctx.shared()
+	o.mu.Lock()
+	if value, ok := o.values[key]; ok {
+		o.mu.Unlock()
+		return value, nil
+	}
+	if c, ok := o.pending[key]; ok {
+		o.mu.Unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	provider, ok := ctx.command.findProvider(key)
+	if !ok || provider.New == nil {
+		o.mu.Unlock()
+		return nil, fmt.Errorf("no provider for %v", key)
+	}
+	c := &creation{done: make(chan struct{})}
+	if o.pending == nil {
+		o.pending = make(map[interface{}]*creation)
+	}
+	o.pending[key] = c
+	o.mu.Unlock()
+
+	// provider is called without lock, so that it could use the context
+	defer func() {
+		o.mu.Lock()
+		delete(o.pending, key)
+		if c.err == nil {
+			o.resources = append(o.resources, resource{value: c.value, close: provider.Close})
+			o.set(key, c.value)
+		}
+		o.mu.Unlock()
+		close(c.done)
+	}()
+	// waiters get an error if provider panics
+	c.err = errProviderPanicked
+	c.value, c.err = provider.New(ctx.providing(key))
+	return c.value, c.err
+}
+
+// providing returns context passed to provider of key
+func (ctx *Context) providing(key interface{}) *Context {
+	creating := make([]interface{}, len(ctx.creating), len(ctx.creating)+1)
+	copy(creating, ctx.creating)
+	return &Context{
+		router:       ctx.router,
+		path:         ctx.path,
+		argvList:     ctx.argvList,
+		nativeArgs:   ctx.nativeArgs,
+		flagSet:      ctx.flagSet,
+		command:      ctx.command,
+		writer:       ctx.writer,
+		console:      ctx.console,
+		color:        ctx.color,
+		app:          ctx.app,
+		cancel:       ctx.cancel,
+		width:        ctx.width,
+		owner:        ctx.shared(),
+		creating:     append(creating, key),
+		HTTPRequest:  ctx.HTTPRequest,
+		HTTPResponse: ctx.HTTPResponse,
+	}
+}
+
+func joinKeys(keys []interface{}) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, fmt.Sprint(key))
+	}
+	return strings.Join(names, " -> ")
+}
+
+// closeResources closes values created by providers in reverse order and
+// returns the first error
+func (ctx *Context) closeResources() (err error) {
+	ctx.mu.Lock()
+	resources := ctx.resources
+	ctx.resources = nil
+	ctx.mu.Unlock()
+	for i := len(resources) - 1; i >= 0; i-- {
+		if r := resources[i]; r.close != nil {
+			if e := r.close(r.value); err == nil {
+				err = e
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userKey struct{}
+
+func TestProvider(t *testing.T) {
+	var events []string
+	provider := func(name string, fail bool) Provider {
+		return Provider{
+			New: func(ctx *Context) (interface{}, error) {
+				if fail {
+					return nil, fmt.Errorf("open %s failed", name)
+				}
+				events = append(events, "open "+name)
+				return name, nil
+			},
+			Close: func(v interface{}) error {
+				events = append(events, "close "+v.(string))
+				return nil
+			},
+		}
+	}
+	root := &Command{
+		Name: "root",
+		OnRootBefore: func(ctx *Context) error {
+			ctx.Set(userKey{}, "admin")
+			return nil
+		},
+	}
+	root.Provide("db", provider("db", false)).Provide("cache", provider("cache", false))
+	sub := root.Register(&Command{
+		Name: "sub",
+		OnAfter: func(ctx *Context) error {
+			events = append(events, "after")
+			return nil
+		},
+		Fn: func(ctx *Context) error {
+			assert.Equal(t, "admin", ctx.Value(userKey{}))
+			assert.Equal(t, "admin", ctx.Context().Value(userKey{}))
+			for _, tt := range []struct{ key, value string }{
+				{"db", "db"},
+				{"cache", "sub-cache"},
+				{"db", "db"},
+			} {
+				v, err := ctx.Get(tt.key)
+				require.Nil(t, err)
+				assert.Equal(t, tt.value, v)
+			}
+			assert.Equal(t, "db", ctx.Context().Value("db"))
+			_, err := ctx.Get("unknown")
+			assert.EqualError(t, err, "no provider for unknown")
+			return nil
+		},
+	})
+	sub.Provide("cache", provider("sub-cache", false))
+
+	// resources are closed in reverse order after OnAfter
+	assert.Nil(t, root.Run([]string{"sub"}))
+	assert.Equal(t, []string{"open db", "open sub-cache", "after", "close sub-cache", "close db"}, events)
+
+	// and closed even if command fails
+	events = nil
+	sub.Fn = func(ctx *Context) error {
+		ctx.Get("db")
+		_, err := ctx.Get("broken")
+		return err
+	}
+	sub.Provide("broken", provider("broken", true))
+	assert.EqualError(t, root.Run([]string{"sub"}), "open broken failed")
+	assert.Equal(t, []string{"open db", "close db"}, events)
+}
+
+func TestNestedProvider(t *testing.T) {
+	var opened []string
+	root := &Command{Name: "root"}
+	root.Provide("config", Provider{
+		New: func(ctx *Context) (interface{}, error) {
+			opened = append(opened, "config")
+			ctx.Set("env", "prod")
+			return "config", nil
+		},
+	})
+	root.Provide("db", Provider{
+		New: func(ctx *Context) (interface{}, error) {
+			config, err := ctx.Get("config")
+			if err != nil {
+				return nil, err
+			}
+			opened = append(opened, "db")
+			return fmt.Sprintf("db(%v, %v)", config, ctx.Value("env")), nil
+		},
+	})
+	root.Provide("a", Provider{New: func(ctx *Context) (interface{}, error) { return ctx.Get("b") }})
+	root.Provide("b", Provider{New: func(ctx *Context) (interface{}, error) { return ctx.Get("a") }})
+	root.Fn = func(ctx *Context) error {
+		var (
+			g      sync.WaitGroup
+			values = make([]interface{}, 4)
+		)
+		for i := range values {
+			g.Add(1)
+			go func(i int) {
+				defer g.Done()
+				v, err := ctx.Get("db")
+				assert.Nil(t, err)
+				values[i] = v
+			}(i)
+		}
+		g.Wait()
+		for _, v := range values {
+			assert.Equal(t, "db(config, prod)", v)
+		}
+		assert.Equal(t, "config", ctx.Value("config"))
+
+		_, err := ctx.Get("a")
+		assert.EqualError(t, err, "cycle in providers: a -> b -> a")
+		return nil
+	}
+	assert.Nil(t, root.Run(nil))
+	// values are created once
+	assert.Equal(t, []string{"config", "db"}, opened)
+}
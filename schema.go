@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/mkideal/expr"
+)
+
+var (
+	decoderType        = reflect.TypeOf((*Decoder)(nil)).Elem()
+	sliceDecoderType   = reflect.TypeOf((*SliceDecoder)(nil)).Elem()
+	counterDecoderType = reflect.TypeOf((*CounterDecoder)(nil)).Elem()
+)
+
+// argvSchema is the immutable description of an argv struct type.
+// It's built once per type and shared by all parses of that type.
+type argvSchema struct {
+	fields []*fieldSchema
+	err    error
+}
+
+// fieldSchema describes a flag field of an argv struct
+type fieldSchema struct {
+	// index is the index sequence for reflect.Value.FieldByIndex
+	index []int
+	field reflect.StructField
+	tag   *tagProperty
+
+	isNumber       bool
+	isDecoder      bool
+	isCounter      bool
+	isNeedDelaySet bool
+
+	// dft is the evaluated default value if isStaticDft is true,
+	// default values which reference environment variables
+	// are evaluated on every parse
+	dft         string
+	isStaticDft bool
+}
+
+// schemaCache caches *argvSchema by struct type
+var schemaCache sync.Map
+
+func getArgvSchema(typ reflect.Type) *argvSchema {
+	if s, ok := schemaCache.Load(typ); ok {
+		return s.(*argvSchema)
+	}
+	s, _ := schemaCache.LoadOrStore(typ, newArgvSchema(typ))
+	return s.(*argvSchema)
+}
+
+func newArgvSchema(typ reflect.Type) *argvSchema {
+	s := &argvSchema{fields: []*fieldSchema{}}
+	s.err = s.scan(typ, nil, "")
+	return s
+}
+
+// scan collects flag fields of typ, fields without tag `group` inherit
+// group of the parent struct
+func (s *argvSchema) scan(typ reflect.Type, parentIndex []int, group string) error {
+	for i, numField := 0, typ.NumField(); i < numField; i++ {
+		field := typ.Field(i)
+		tag, isEmpty, err := parseTag(field.Name, field.Tag)
+		if err != nil {
+			return err
+		}
+		if tag == nil {
+			continue
+		}
+		if tag.group == "" {
+			tag.group = group
+		}
+		index := make([]int, len(parentIndex)+1)
+		copy(index, parentIndex)
+		index[len(parentIndex)] = i
+
+		// if `cli` tag is empty and the field is a struct
+		if isEmpty && field.Type.Kind() == reflect.Struct {
+			if err := s.scan(field.Type, index, tag.group); err != nil {
+				return err
+			}
+			continue
+		}
+		fs, err := newFieldSchema(field, index, tag)
+		if err != nil {
+			return err
+		}
+		s.fields = append(s.fields, fs)
+	}
+	return nil
+}
+
+func newFieldSchema(field reflect.StructField, index []int, tag *tagProperty) (*fieldSchema, error) {
+	fs := &fieldSchema{
+		index: index,
+		field: field,
+		tag:   tag,
+	}
+	var (
+		typ     = field.Type
+		ptrType = typ
+	)
+	if typ.Kind() != reflect.Ptr {
+		ptrType = reflect.PtrTo(typ)
+	}
+	fs.isNumber = isIntegerKind(typ.Kind()) || isFloatKind(typ.Kind())
+	fs.isDecoder = typ.Implements(decoderType) || ptrType.Implements(decoderType)
+	fs.isCounter = ptrType.Implements(counterDecoderType)
+	isSliceDecoder := typ.Implements(sliceDecoderType) || ptrType.Implements(sliceDecoderType)
+	fs.isNeedDelaySet = typ.Kind() != reflect.Slice && typ.Kind() != reflect.Map && !isSliceDecoder
+
+	if !strings.Contains(tag.dft, "$") {
+		dft, err := fs.evalDefault(nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.dft = dft
+		fs.isStaticDft = true
+	}
+	return fs, nil
+}
+
+// defaultValue returns the evaluated default value of the field,
+// environment variables are looked up by getenv
+func (fs *fieldSchema) defaultValue(getenv func(string) string) (string, error) {
+	if fs.isStaticDft {
+		return fs.dft, nil
+	}
+	return fs.evalDefault(getenv)
+}
+
+func (fs *fieldSchema) evalDefault(getenv func(string) string) (string, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	dft, err := parseExpressionWithEnv(fs.tag.dft, fs.isNumber, getenv)
+	if err != nil {
+		return "", err
+	}
+	if fs.isNumber && !fs.isDecoder {
+		v, err := expr.Eval(dft, nil, nil)
+		if err == nil {
+			if isIntegerKind(fs.field.Type.Kind()) {
+				dft = fmt.Sprintf("%d", v.Int())
+			} else {
+				dft = fmt.Sprintf("%f", v.Float())
+			}
+		}
+	}
+	return dft, nil
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgvSchema(t *testing.T) {
+	type baseT struct {
+		Help bool `cli:"!h,help" usage:"display help"`
+	}
+	type schemaT struct {
+		baseT
+		Port    int     `cli:"p,port" dft:"8000+80"`
+		Rate    float64 `cli:"rate" dft:"1.5"`
+		Env     string  `cli:"env" dft:"$CLI_TEST_SCHEMA_ENV"`
+		Counter Counter `cli:"v"`
+		Ignored string  `cli:"-"`
+	}
+	typ := reflect.TypeOf(schemaT{})
+	schema := getArgvSchema(typ)
+	require.Nil(t, schema.err)
+	assert.True(t, schema == getArgvSchema(typ))
+	require.Len(t, schema.fields, 5)
+
+	assert.Equal(t, []int{0, 0}, schema.fields[0].index)
+	assert.Equal(t, []string{"-h"}, schema.fields[0].tag.shortNames)
+	assert.True(t, schema.fields[1].isStaticDft)
+	assert.Equal(t, "8080", schema.fields[1].dft)
+	assert.Equal(t, "1.500000", schema.fields[2].dft)
+	assert.False(t, schema.fields[3].isStaticDft)
+	assert.True(t, schema.fields[4].isCounter)
+
+	// defaults which reference environment variables must be evaluated on every parse
+	clr := color.Color{}
+	for _, env := range []string{"first", "second"} {
+		os.Setenv("CLI_TEST_SCHEMA_ENV", env)
+		argv := new(schemaT)
+		require.Nil(t, parseArgv([]string{}, argv, clr).err)
+		assert.Equal(t, env, argv.Env)
+		assert.Equal(t, 8080, argv.Port)
+	}
+	os.Unsetenv("CLI_TEST_SCHEMA_ENV")
+}
+
+type benchArgT struct {
+	Helper
+	Host    string            `cli:"H,host" usage:"specify host" dft:"0.0.0.0"`
+	Port    uint16            `cli:"p,port" usage:"specify port" dft:"8000+80"`
+	Verbose Counter           `cli:"v" usage:"verbose level"`
+	Tags    []string          `cli:"t,tag" usage:"tags"`
+	Labels  map[string]string `cli:"l,label" usage:"labels"`
+	Timeout float64           `cli:"timeout" usage:"timeout in seconds" dft:"1.5"`
+	Name    string            `cli:"*name" usage:"name of service"`
+}
+
+var benchArgs = []string{"--name=bench", "-p", "9090", "-vv", "-tA", "-tB", "-lk=v"}
+
+func BenchmarkParse(b *testing.B) {
+	clr := color.Color{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := parseArgv(benchArgs, new(benchArgT), clr).err; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseWithoutSchemaCache(b *testing.B) {
+	clr := color.Color{}
+	typ := reflect.TypeOf(benchArgT{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		schemaCache.Delete(typ)
+		if err := parseArgv(benchArgs, new(benchArgT), clr).err; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUsage(b *testing.B) {
+	clr := color.Color{}
+	argvList := []interface{}{new(benchArgT)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		usage(argvList, clr, NormalStyle)
+	}
+}
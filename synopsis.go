@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// synopsis returns the usage line of the command starting with path, which
+// is Synopsis if not empty, e.g.
+//
+//	app deploy --env=VALUE [OPTIONS] COMMAND
+func (cmd *Command) synopsis(path string) string {
+	if cmd.Synopsis != "" {
+		return cmd.Synopsis
+	}
+	var words []string
+	if path != "" {
+		words = append(words, path)
+	}
+	fs, _ := usageFlags(cmd.argvList())
+	hasOptional := false
+	for _, fl := range fs {
+		if !fl.tag.isRequired {
+			hasOptional = true
+			continue
+		}
+		names := fl.tag.names()
+		word := names[len(names)-1]
+		if valueName := fl.docValueName(); valueName != "" {
+			word += "=" + valueName
+		}
+		words = append(words, word)
+	}
+	if hasOptional {
+		words = append(words, "[OPTIONS]")
+	}
+	if len(cmd.docChildren()) > 0 {
+		if cmd.Fn == nil {
+			words = append(words, "COMMAND")
+		} else {
+			words = append(words, "[COMMAND]")
+		}
+	}
+	if args := synopsisArgs(probeNumArg(cmd.NumArg)); args != "" {
+		words = append(words, args)
+	}
+	return strings.Join(words, " ")
+}
+
+// programPath returns fullPath with base name of root's name, which is the
+// program name if empty, e.g. "app deploy" of root "/usr/bin/app"
+func (cmd *Command) programPath() string {
+	name := cmd.Root().Name
+	if name == "" {
+		name = os.Args[0]
+	}
+	return strings.TrimSpace(filepath.Base(name) + " " + cmd.Path())
+}
+
+// synopsisArgs returns arguments part of usage line by range of number
+// of arguments
+func synopsisArgs(s *ArgsSchema) string {
+	if s == nil {
+		return ""
+	}
+	var words []string
+	if s.Min == 1 {
+		words = append(words, "ARG")
+	} else {
+		for i := 1; i <= s.Min; i++ {
+			words = append(words, fmt.Sprintf("ARG%d", i))
+		}
+	}
+	switch {
+	case s.Max == s.Min:
+	case s.Max == s.Min+1:
+		words = append(words, "[ARG]")
+	default:
+		words = append(words, "[ARGS...]")
+	}
+	return strings.Join(words, " ")
+}
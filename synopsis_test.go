@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynopsis(t *testing.T) {
+	type argT struct {
+		Env  string `cli:"*e,env" usage:"environment"`
+		User string `cli:"*u" name:"USER"`
+		Dry  bool   `cli:"dry"`
+	}
+	type requiredT struct {
+		Env string `cli:"*env"`
+	}
+	root := &Command{Name: "/usr/bin/app"}
+	deploy := root.Register(&Command{Name: "deploy", Argv: func() interface{} { return new(argT) }, Fn: donothing})
+	get := root.Register(&Command{Name: "get", Argv: func() interface{} { return new(requiredT) }, Fn: donothing})
+	get.Register(&Command{Name: "pods", Fn: donothing})
+	get.Register(&Command{Name: "_hidden", Fn: donothing})
+	custom := root.Register(&Command{Name: "custom", Synopsis: "app custom <target>", Fn: donothing})
+
+	for _, tt := range []struct {
+		cmd    *Command
+		numArg NumCheckFunc
+		want   string
+	}{
+		{root, nil, "app COMMAND"},
+		{deploy, nil, "app deploy --env=VALUE -u=USER [OPTIONS]"},
+		{deploy, ExactN(1), "app deploy --env=VALUE -u=USER [OPTIONS] ARG"},
+		{deploy, ExactN(2), "app deploy --env=VALUE -u=USER [OPTIONS] ARG1 ARG2"},
+		{deploy, AtMost(1), "app deploy --env=VALUE -u=USER [OPTIONS] [ARG]"},
+		{deploy, AtLeast(1), "app deploy --env=VALUE -u=USER [OPTIONS] ARG [ARGS...]"},
+		{get, nil, "app get --env=VALUE [COMMAND]"},
+		{get.Route([]string{"pods"}), AtMost(5), "app get pods [ARGS...]"},
+		{custom, ExactN(1), "app custom <target>"},
+	} {
+		tt.cmd.NumArg = tt.numArg
+		assert.Equal(t, tt.want, tt.cmd.synopsis(tt.cmd.programPath()))
+	}
+}
+
+func TestNoSynopsis(t *testing.T) {
+	root := &Command{
+		Name: "app",
+		Desc: "Usage: app [-v] FILE",
+		Argv: func() interface{} { return new(struct{ Verbose bool `cli:"v"` }) },
+		Fn:   donothing,
+	}
+	app := NewApp(root)
+	app.Color = ColorNever
+	assert.Contains(t, app.Usage(root), "Usage: app [OPTIONS]\n")
+	app.NoSynopsis = true
+	assert.Equal(t, "Usage: app [-v] FILE\n\nOptions:\n\n  -v   \n", app.Usage(root))
+
+	// documents of unnamed root don't use the program name
+	unnamed := &Command{Fn: donothing}
+	child := unnamed.Register(&Command{Name: "child", Fn: donothing})
+	assert.Equal(t, "child", child.fullPath())
+	assert.Equal(t, "child", child.docName())
+	assert.Equal(t, "child", child.Schema().Path)
+	assert.Equal(t, "child", child.synopsis(child.fullPath()))
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"reflect"
 	"strings"
+	"time"
 )
 
 const (
@@ -17,6 +18,14 @@ const (
 	tagParser = "parser"
 	tagSep    = "sep" // used to seperate key/value pair of map, default is `=`
 
+	tagChoices = "choices" // comma-separated values allowed
+
+	tagPromptTimeout = "prompt-timeout" // duration like `30s`
+
+	tagComplete = "complete" // completer of value, see Completer
+
+	tagGroup = "group" // heading of the section in usage
+
 	dashOne = "-"
 	dashTwo = "--"
 
@@ -39,12 +48,20 @@ type tagProperty struct {
 	isEdit   bool   `edit:"xxx"`
 	editFile string `edit:"FILE:xxx"`
 
-	usage         string            `usage:"usage string"`
-	dft           string            `dft:"default value or expression"`
-	name          string            `name:"tag reference name"`
-	prompt        string            `prompt:"prompt string"`
-	sep           string            `sep:"string for seperate kay/value pair of map"`
-	parserCreator FlagParserCreator `parser:"parser for flag"`
+	usage      string `usage:"usage string"`
+	dft        string `dft:"default value or expression"`
+	name       string `name:"tag reference name"`
+	prompt     string `prompt:"prompt string"`
+	sep        string `sep:"string for seperate kay/value pair of map"`
+	parserName string `parser:"parser for flag"`
+
+	choices []string `choices:"a,b,c"`
+
+	promptTimeout time.Duration `prompt-timeout:"30s"`
+
+	completer string `complete:"files:*.yaml"`
+
+	group string `group:"Networking"`
 
 	// flag names
 	shortNames []string
@@ -104,12 +121,30 @@ func parseTag(fieldName string, structTag reflect.StructTag) (p *tagProperty, is
 	p.prompt = tag.Get(tagPrompt)
 
 	// `parser` TAG
-	if parserName := tag.Get(tagParser); parserName != "" {
-		if parserCreator, ok := parserCreators[parserName]; ok {
-			p.parserCreator = parserCreator
+	p.parserName = tag.Get(tagParser)
+
+	// `choices` TAG
+	if choices := tag.Get(tagChoices); choices != "" {
+		for _, choice := range strings.Split(choices, ",") {
+			if choice = strings.TrimSpace(choice); choice != "" {
+				p.choices = append(p.choices, choice)
+			}
 		}
 	}
 
+	// `prompt-timeout` TAG
+	if timeout := tag.Get(tagPromptTimeout); timeout != "" {
+		if p.promptTimeout, err = time.ParseDuration(timeout); err != nil {
+			return
+		}
+	}
+
+	// `complete` TAG
+	p.completer = tag.Get(tagComplete)
+
+	// `group` TAG
+	p.group = tag.Get(tagGroup)
+
 	// `sep` TAG
 	p.sep = defaultSepForKeyValueOfMap
 	if sep := tag.Get(tagSep); sep != "" {
@@ -149,3 +184,10 @@ func parseTag(fieldName string, structTag reflect.StructTag) (p *tagProperty, is
 	}
 	return
 }
+
+// names returns short names and long names
+func (p *tagProperty) names() []string {
+	names := make([]string, 0, len(p.shortNames)+len(p.longNames))
+	names = append(names, p.shortNames...)
+	return append(names, p.longNames...)
+}
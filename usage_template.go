@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/labstack/gommon/color"
+)
+
+// usageTemplate is shared by the builtin templates, which differ only in
+// the function rendering flags
+const usageTemplate = `{{with .Desc}}{{.}}
+
+{{end}}{{with .Synopsis}}{{bold "Usage"}}: {{.}}
+
+{{end}}{{with .Text}}{{.}}
+
+{{end}}
+{{- range $i, $group := .Groups}}{{if $i}}
+{{end}}{{bold (or $group.Name "Options")}}:
+
+{{%s $group.Flags}}{{end}}
+{{- range $i, $category := .Categories}}{{if or $i $.Groups}}
+{{end}}{{bold (or $category.Name "Commands")}}:
+
+{{commandTable $category.Commands}}{{end}}
+{{- if .Examples}}{{if or .Groups .Categories}}
+{{end}}{{bold "Examples"}}:
+
+{{examples .Examples}}{{end}}
+{{- if .Topics}}{{if or .Groups .Categories .Examples}}
+{{end}}{{bold "Help topics"}}:
+
+{{topicTable .Topics}}{{end}}`
+
+var (
+	// NormalUsageTemplate is the builtin usage template of NormalStyle and
+	// DenseNormalStyle, which lists flags in a table
+	NormalUsageTemplate = fmt.Sprintf(usageTemplate, "flagTable")
+
+	// ManualUsageTemplate is the builtin usage template of ManualStyle and
+	// DenseManualStyle, which lists usage of flags under their names
+	ManualUsageTemplate = fmt.Sprintf(usageTemplate, "flagList")
+)
+
+type (
+	// UsageData is the data which usage templates are executed with.
+	// Functions below could be called in templates:
+	//
+	//	bold, red, green, yellow, blue, grey ...  colorize text if enabled
+	//	color NAME TEXT      colorize TEXT by NAME like "bold" or "red"
+	//	wrap INDENT TEXT     wrap TEXT to Width with hanging indentation
+	//	indent N TEXT        indent every line of TEXT by N spaces
+	//	padRight N TEXT      append spaces until TEXT takes N columns
+	//	padLeft N TEXT       prepend spaces until TEXT takes N columns
+	//	width TEXT           number of columns TEXT takes
+	//	join SEP LIST        join strings
+	//	flagTable FLAGS      flags in a table aligned with all Flags
+	//	flagList FLAGS       flags with usage under their names
+	//	commandTable CMDS    commands aligned with all Children
+	//	topicTable TOPICS    help topics in a table
+	//	examples EXAMPLES    examples with descriptions as comments
+	UsageData struct {
+		Command    *Command
+		Name       string
+		Path       string // path including name of root, e.g. "app deploy"
+		Desc       string
+		Synopsis   string // usage line, empty if App.NoSynopsis
+		Text       string
+		Flags      []*UsageFlag     // all flags, including flags of global ancestors
+		Groups     []*UsageGroup    // flags in sections, ungrouped ones first
+		Children   []*UsageCommand  // all children
+		Categories []*UsageCategory // children in sections, uncategorized ones first
+		Examples   []Example
+		Topics     []*HelpTopic
+		Style      UsageStyle
+		Width      int // width of terminal, 0 if text shouldn't be wrapped
+	}
+
+	// UsageFlag describes a flag in UsageData
+	UsageFlag struct {
+		Names     []string
+		ValueName string
+		Default   string
+		Usage     string
+		Choices   []string
+		Required  bool
+		Group     string
+
+		flag *flag
+	}
+
+	// UsageGroup is a section of flags in UsageData, Name is empty for
+	// ungrouped flags
+	UsageGroup struct {
+		Name  string
+		Flags []*UsageFlag
+	}
+
+	// UsageCommand describes a child command in UsageData
+	UsageCommand struct {
+		Name     string
+		Aliases  []string
+		Desc     string
+		Category string
+
+		command *Command
+	}
+
+	// UsageCategory is a section of children in UsageData, Name is empty
+	// for children without category
+	UsageCategory struct {
+		Name     string
+		Commands []*UsageCommand
+	}
+)
+
+// usageTemplateText returns template of usage of the command, which is
+// UsageTemplate of the command or it's nearest ancestor, UsageTemplate of
+// app, or the builtin template of style
+func (cmd *Command) usageTemplateText(app *App, style UsageStyle) string {
+	for c := cmd; c != nil; c = c.parent {
+		if c.UsageTemplate != "" {
+			return c.UsageTemplate
+		}
+	}
+	if app.UsageTemplate != "" {
+		return app.UsageTemplate
+	}
+	if style == ManualStyle || style == DenseManualStyle {
+		return ManualUsageTemplate
+	}
+	return NormalUsageTemplate
+}
+
+// usageData builds UsageData of the command
+func (cmd *Command) usageData(app *App, style UsageStyle, width int) *UsageData {
+	data := &UsageData{
+		Command:  cmd,
+		Name:     cmd.Name,
+		Path:     cmd.fullPath(),
+		Desc:     cmd.Desc,
+		Text:     cmd.Text,
+		Examples: cmd.Examples,
+		Topics:   cmd.topics,
+		Style:    style,
+		Width:    width,
+	}
+	if !app.NoSynopsis {
+		data.Synopsis = cmd.synopsis(cmd.programPath())
+	}
+	argvList := cmd.argvList()
+	if !isEmptyArgvList(argvList) {
+		fs, ok := usageFlags(argvList)
+		if !ok {
+			// keep the heading of options
+			data.Groups = append(data.Groups, &UsageGroup{})
+		}
+		for _, group := range fs.groups() {
+			g := &UsageGroup{Name: group.name}
+			for _, fl := range group.flags {
+				g.Flags = append(g.Flags, &UsageFlag{
+					Names:     fl.tag.names(),
+					ValueName: fl.tag.name,
+					Default:   fl.tag.dft,
+					Usage:     fl.tag.usage,
+					Choices:   fl.tag.choices,
+					Required:  fl.tag.isRequired,
+					Group:     fl.tag.group,
+					flag:      fl,
+				})
+			}
+			data.Flags = append(data.Flags, g.Flags...)
+			data.Groups = append(data.Groups, g)
+		}
+	}
+	for _, category := range cmd.categories() {
+		c := &UsageCategory{Name: category.name}
+		for _, child := range category.commands {
+			c.Commands = append(c.Commands, &UsageCommand{
+				Name:     child.Name,
+				Aliases:  child.Aliases,
+				Desc:     child.Desc,
+				Category: child.Category,
+				command:  child,
+			})
+		}
+		data.Children = append(data.Children, c.Commands...)
+		data.Categories = append(data.Categories, c)
+	}
+	return data
+}
+
+// usageFuncs returns functions of usage templates, which render flags and
+// commands aligned with all ones of data
+func usageFuncs(data *UsageData, clr color.Color) template.FuncMap {
+	colors := map[string]func(interface{}, ...string) string{
+		"bold":      clr.Bold,
+		"dim":       clr.Dim,
+		"italic":    clr.Italic,
+		"underline": clr.Underline,
+		"black":     clr.Black,
+		"red":       clr.Red,
+		"green":     clr.Green,
+		"yellow":    clr.Yellow,
+		"blue":      clr.Blue,
+		"magenta":   clr.Magenta,
+		"cyan":      clr.Cyan,
+		"white":     clr.White,
+		"grey":      clr.Grey,
+	}
+	var (
+		all   flagSlice
+		width = data.Width
+		style = data.Style
+	)
+	for _, fl := range data.Flags {
+		all = append(all, fl.flag)
+	}
+	rows := func(flags []*UsageFlag) flagSlice {
+		fs := make(flagSlice, 0, len(flags))
+		for _, fl := range flags {
+			fs = append(fs, fl.flag)
+		}
+		return fs
+	}
+	funcs := template.FuncMap{
+		"color": func(name string, s interface{}) (string, error) {
+			fn, ok := colors[name]
+			if !ok {
+				return "", fmt.Errorf("unknown color %q", name)
+			}
+			return fn(s), nil
+		},
+		"wrap": func(indent int, s string) string {
+			return wrapIndent(s, width, indent)
+		},
+		"indent": func(n int, s string) string {
+			prefix := strings.Repeat(" ", n)
+			lines := strings.Split(s, "\n")
+			for i, line := range lines {
+				if line != "" {
+					lines[i] = prefix + line
+				}
+			}
+			return strings.Join(lines, "\n")
+		},
+		"padRight": func(n int, s string) string { return padRight(s, n) },
+		"padLeft":  func(n int, s string) string { return padLeft(s, n) },
+		"width":    displayWidth,
+		"join":     func(sep string, list []string) string { return strings.Join(list, sep) },
+		"flagTable": func(flags []*UsageFlag) string {
+			s := NormalStyle
+			if style == DenseNormalStyle || style == DenseManualStyle {
+				s = DenseNormalStyle
+			}
+			return all.rowsString(rows(flags), clr, s, width)
+		},
+		"flagList": func(flags []*UsageFlag) string {
+			s := ManualStyle
+			if style == DenseNormalStyle || style == DenseManualStyle {
+				s = DenseManualStyle
+			}
+			return all.sectionString(rows(flags), clr, s, width)
+		},
+		"commandTable": func(commands []*UsageCommand) string {
+			children := make([]*Command, 0, len(commands))
+			for _, c := range commands {
+				children = append(children, c.command)
+			}
+			return data.Command.childrenDescriptionsOf(children, "  ", "   ", width)
+		},
+		"topicTable": func(topics []*HelpTopic) string {
+			return topicsDescriptions(topics, "  ", "   ", width)
+		},
+		"examples": func(examples []Example) string {
+			return examplesString(examples, "  ", width)
+		},
+	}
+	for name, fn := range colors {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// renderUsage executes usage template text with data of the command
+func (cmd *Command) renderUsage(app *App, text string, clr color.Color, style UsageStyle, width int) (string, error) {
+	data := cmd.usageData(app, style, width)
+	tpl, err := template.New("usage").Funcs(usageFuncs(data, clr)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
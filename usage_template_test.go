@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageTemplate(t *testing.T) {
+	type argT struct {
+		Name string `cli:"*n,name" usage:"your name"`
+		Port int    `cli:"p,port" usage:"port to listen" dft:"8080" group:"Networking"`
+	}
+	root := &Command{
+		Name: "app",
+		Desc: "an app",
+		Argv: func() interface{} { return new(argT) },
+		Fn:   donothing,
+	}
+	sub := root.Register(&Command{Name: "sub", Desc: "a sub-command", Category: "Basic", Fn: donothing})
+	app := NewApp(root)
+
+	builtin := app.Usage(root)
+	app.UsageTemplate = NormalUsageTemplate
+	assert.Equal(t, builtin, app.Usage(root))
+
+	app.UsageTemplate = `{{.Name}} - {{.Desc}}
+{{range .Groups}}[{{or .Name "General"}}]
+{{range .Flags}}{{padRight 10 (join "," .Names)}}{{.Usage}}{{if .Required}} (required){{end}}
+{{end}}{{end}}{{range .Categories}}[{{.Name}}]
+{{commandTable .Commands}}{{end}}`
+	assert.Equal(t, `app - an app
+[General]
+-n,--name your name (required)
+[Networking]
+-p,--port port to listen
+[Basic]
+  sub   a sub-command
+`, app.Usage(root))
+
+	// template of root applies to descendants and overrides app
+	root.UsageTemplate = `{{bold .Path}}: {{.Desc}}{{with .Children}} {{len .}}{{end}}
+{{wrap 2 "a long line which is wrapped to the width of terminal"}}
+{{color "red" "!"}}`
+	app.Getenv = func(key string) string {
+		return map[string]string{ColumnsEnv: "30"}[key]
+	}
+	assert.Equal(t, "app sub: a sub-command\na long line which is wrapped\n  to the width of terminal\n!", app.Usage(sub))
+	assert.Equal(t, "app: an app 1\na long line which is wrapped\n  to the width of terminal\n!", app.Usage(root))
+
+	root.UsageTemplate = `{{color "pink" .Name}}`
+	assert.Contains(t, app.Usage(root), `usage template: template: usage:1:2: executing "usage" at <color "pink" .Name>: error calling color: unknown color "pink"`)
+}
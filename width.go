@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ColumnsEnv is the environment variable which overrides width of terminal
+// that usage is wrapped to
+const ColumnsEnv = "COLUMNS"
+
+// minWrapWidth is the minimum width of text wrapped, text isn't wrapped if
+// the room is narrower than it
+const minWrapWidth = 20
+
+// width returns width of terminal which w writes to, it's 0 if w isn't a
+// terminal and ColumnsEnv isn't set
+func (app *App) width(w io.Writer, fds ...uintptr) int {
+	if n, err := strconv.Atoi(app.getenv(ColumnsEnv)); err == nil && n > 0 {
+		return n
+	}
+	fd := -1
+	if len(fds) > 0 {
+		fd = int(fds[0])
+	} else if f, ok := w.(*os.File); ok {
+		fd = int(f.Fd())
+	}
+	if fd >= 0 && terminal.IsTerminal(fd) {
+		if width, _, err := terminal.GetSize(fd); err == nil {
+			return width
+		}
+	}
+	return 0
+}
+
+// wideRanges are ranges of East Asian wide and fullwidth characters and
+// emoji, which take two columns
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// runeWidth returns number of columns r takes in terminal
+func runeWidth(r rune) int {
+	if r < 0x20 || (r >= 0x7F && r < 0xA0) || r == 0x200B ||
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// escapeLen returns length of ANSI escape sequence at beginning of s,
+// which takes no column, 0 returned if s doesn't start with one
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != 0x1B || s[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7E {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// displayWidth returns number of columns s takes in terminal, escape
+// sequences of colors are ignored
+func displayWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width += runeWidth(r)
+		i += size
+	}
+	return width
+}
+
+// padRight appends spaces to s until it takes width columns
+func padRight(s string, width int) string {
+	if n := width - displayWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// padLeft prepends spaces to s until it takes width columns
+func padLeft(s string, width int) string {
+	if n := width - displayWidth(s); n > 0 {
+		return strings.Repeat(" ", n) + s
+	}
+	return s
+}
+
+// wrapToken is a word, or a wide character, of text wrapped
+type wrapToken struct {
+	text  string
+	width int
+	space bool // whether spaces precede it
+}
+
+func tokenize(line string) []wrapToken {
+	var (
+		tokens []wrapToken
+		word   strings.Builder
+		width  = 0
+		space  = false
+		flush  = func() {
+			if word.Len() > 0 {
+				tokens = append(tokens, wrapToken{word.String(), width, space})
+				word.Reset()
+				width, space = 0, false
+			}
+		}
+	)
+	for i := 0; i < len(line); {
+		if n := escapeLen(line[i:]); n > 0 {
+			word.WriteString(line[i : i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		switch w := runeWidth(r); {
+		case r == ' ' || r == '\t':
+			flush()
+			space = true
+		case w == 2:
+			// lines could break between wide characters
+			flush()
+			tokens = append(tokens, wrapToken{string(r), w, space})
+			space = false
+		default:
+			word.WriteRune(r)
+			width += w
+		}
+	}
+	flush()
+	return tokens
+}
+
+// wrapText wraps s into lines no wider than width, words wider than width
+// take a line alone. Newlines of s are kept, lines which fit are kept as is
+// and lines wrapped keep their indentation. s is returned as is if width is
+// less than minWrapWidth.
+func wrapText(s string, width int) []string {
+	if width < minWrapWidth {
+		return strings.Split(s, "\n")
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		text := strings.TrimLeft(paragraph, " \t")
+		indent := paragraph[:len(paragraph)-len(text)]
+		room := width - displayWidth(strings.Replace(indent, "\t", "        ", -1))
+		if displayWidth(paragraph) <= width || room < minWrapWidth {
+			lines = append(lines, paragraph)
+			continue
+		}
+		var (
+			line      strings.Builder
+			lineWidth = 0
+		)
+		for _, tok := range tokenize(text) {
+			w := tok.width
+			if tok.space && lineWidth > 0 {
+				w++
+			}
+			if lineWidth > 0 && lineWidth+w > room {
+				lines = append(lines, indent+line.String())
+				line.Reset()
+				lineWidth, w = 0, tok.width
+			}
+			if tok.space && lineWidth > 0 {
+				line.WriteByte(' ')
+			}
+			line.WriteString(tok.text)
+			lineWidth += w
+		}
+		lines = append(lines, indent+line.String())
+	}
+	return lines
+}
+
+// wrapIndent wraps s to width and indents lines except the first one by
+// indent columns, trailing newlines of s are kept
+func wrapIndent(s string, width, indent int) string {
+	if width <= 0 {
+		return s
+	}
+	body := strings.TrimRight(s, "\n")
+	trailing := s[len(body):]
+	lines := wrapText(body, width-indent)
+	return strings.Join(lines, "\n"+strings.Repeat(" ", indent)) + trailing
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	for s, want := range map[string]int{
+		"":                   0,
+		"hello":              5,
+		"你好":                 4,
+		"ｈｉ":                 4,
+		"é":                 1,
+		"\x1b[1mbold\x1b[0m": 4,
+	} {
+		assert.Equal(t, want, displayWidth(s), "%q", s)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		width int
+		lines []string
+	}{
+		{"the quick brown fox jumps over the lazy dog", 20, []string{"the quick brown fox", "jumps over the lazy", "dog"}},
+		{"a verylongwordwhichcannotbebroken b", 20, []string{"a", "verylongwordwhichcannotbebroken", "b"}},
+		{"keep\n\nnewlines", 20, []string{"keep", "", "newlines"}},
+		{"中文文本可以在任意两个字之间换行，无需空格", 20, []string{"中文文本可以在任意两", "个字之间换行，无需空", "格"}},
+		{"not wrapped if too narrow", 10, []string{"not wrapped if too narrow"}},
+		{"  fast    quick run", 20, []string{"  fast    quick run"}},
+		{"  indented lines keep their indentation", 24, []string{"  indented lines keep", "  their indentation"}},
+	} {
+		assert.Equal(t, tt.lines, wrapText(tt.s, tt.width), tt.s)
+	}
+	assert.Equal(t, "one two three four\n    five six seven\n\n", wrapIndent("one two three four five six seven\n\n", 24, 4))
+	assert.Equal(t, "one two", wrapIndent("one two", 0, 4))
+}
+
+func TestUsageWrapping(t *testing.T) {
+	type argT struct {
+		Name string `cli:"n,name" usage:"名字，用于问候"`
+		Long string `cli:"*long" usage:"a very long usage text which runs off a narrow terminal"`
+	}
+	root := &Command{
+		Name: "app",
+		Argv: func() interface{} { return new(argT) },
+		Fn:   donothing,
+	}
+	root.Register(&Command{Name: "sub", Desc: "a sub-command whose description is long enough to wrap", Aliases: []string{"s"}})
+	root.Register(&Command{Name: "x", Desc: "名字"})
+
+	app := NewApp(root)
+	app.Getenv = func(key string) string {
+		return map[string]string{ColumnsEnv: "44"}[key]
+	}
+	assert.Equal(t, `Usage: app --long=VALUE [OPTIONS] [COMMAND]
+
+Options:
+
+  -n, --name   名字，用于问候
+      --long  *a very long usage text which
+               runs off a narrow terminal
+
+Commands:
+
+  sub   a sub-command whose description is
+        long enough to wrap (aliases s)
+  x     名字
+`, app.Usage(root))
+
+	// lines which fit are kept with their indentation
+	type modeT struct {
+		Mode string `cli:"mode" usage:"mode, one of:\n  fast    quick run\n  slow    careful run"`
+	}
+	mode := &Command{Name: "mode", Argv: func() interface{} { return new(modeT) }, Fn: donothing}
+	app = NewApp(mode)
+	app.Getenv = func(key string) string {
+		return map[string]string{ColumnsEnv: "60"}[key]
+	}
+	assert.Contains(t, app.Usage(mode), "  --mode   mode, one of:\n"+
+		"             fast    quick run\n"+
+		"             slow    careful run\n")
+
+	app = NewApp(root)
+	// not wrapped without COLUMNS
+	app.Getenv = func(string) string { return "" }
+	assert.Contains(t, app.Usage(root), "  --long  *a very long usage text which runs off a narrow terminal\n")
+}